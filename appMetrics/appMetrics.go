@@ -21,34 +21,176 @@ package appmetrics
 
 import (
 	"azure_app_exporter/logging"
+	"runtime"
+
+	buildinfo "azure_app_exporter/buildInfo"
+
+	appsettings "azure_app_exporter/appSettings"
+	globalstate "azure_app_exporter/globalState"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// Set from [metrics] layout by Init
+var compactLayout bool
+
+// Pick the label names of a credential value series according to [metrics] layout
+func layoutLabels(compact []string, legacy []string) []string {
+	if compactLayout {
+		return compact
+	}
+
+	return legacy
+}
+
+// Whether the credential value series only carry identifying labels, see [metrics] layout
+func CompactLayout() bool {
+	return compactLayout
+}
+
 var (
-	TokenSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+	TokenSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name: "azure_api_token_update_duration_seconds",
 		Help: "How many seconds it takes to update the Azure API token.",
-	})
-	TokenFailures = prometheus.NewCounter(prometheus.CounterOpts{
+	}, []string{"tenant_id"})
+	TokenFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "azure_api_token_update_failures",
 		Help: "How many times updating the Azure API token has failed.",
-	})
-	ApplicationsSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+	}, []string{"tenant_id"})
+	ApplicationsSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name: "azure_applications_update_duration_seconds",
 		Help: "How many seconds it takes to update the in-memory cache of Azure applications.",
-	})
-	ApplicationsFailures = prometheus.NewCounter(prometheus.CounterOpts{
+	}, []string{"tenant_id"})
+	ApplicationsFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "azure_applications_update_failures",
 		Help: "How many times updating the cached Azure applications has failed.",
-	})
+	}, []string{"tenant_id"})
+	ServicePrincipalsSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "azure_service_principals_update_duration_seconds",
+		Help: "How many seconds it takes to update the in-memory cache of Azure service principals.",
+	}, []string{"tenant_id"})
+	ServicePrincipalsFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "azure_service_principals_update_failures",
+		Help: "How many times updating the cached Azure service principals has failed.",
+	}, []string{"tenant_id"})
+	PrunedSeries = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "azure_metrics_pruned_series",
+		Help: "How many series are withheld because their cache was not refreshed within the prune interval.",
+	}, []string{"tenant_id", "cache"})
+
+	TokenLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "azure_api_token_last_success_timestamp_seconds",
+		Help: "Unix timestamp of the last successful Azure API token update.",
+	}, []string{"tenant_id"})
+	TokenExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "azure_api_token_expiry_timestamp_seconds",
+		Help: "Unix timestamp at which the current Azure API token expires.",
+	}, []string{"tenant_id"})
+	ApplicationsLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "azure_applications_last_success_timestamp_seconds",
+		Help: "Unix timestamp of the last successful update of the cached Azure applications.",
+	}, []string{"tenant_id"})
+	ApplicationsCached = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "azure_applications_cached",
+		Help: "How many Azure applications are currently cached.",
+	}, []string{"tenant_id"})
+	ApplicationCredentialsCached = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "azure_application_credentials_cached",
+		Help: "How many credentials of the cached Azure applications are currently cached, partitioned by type (password or certificate).",
+	}, []string{"tenant_id", "type"})
+
+	RequestRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "azure_app_exporter_request_retries",
+		Help: "How many times a request to the login or Graph endpoints was retried after being throttled, partitioned by endpoint.",
+	}, []string{"endpoint"})
+	ThrottledResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "azure_app_exporter_throttled_responses",
+		Help: "How many responses from the login or Graph endpoints were HTTP 429 or 503, partitioned by endpoint and status code.",
+	}, []string{"endpoint", "status"})
+
+	ConfigReloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "azure_app_exporter_config_reloads",
+		Help: "How many times the settings file was reloaded, partitioned by result (success or failure).",
+	}, []string{"result"})
+
+	ConfigHash = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "azure_app_exporter_config_hash_info",
+		Help: "SHA-256 of the settings file currently in use, always 1.",
+	}, []string{"hash"})
 
-	ApplicationPasswordSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "azure_application_password_remaining_seconds",
-		Help: "Seconds remaining until the password credential expires.",
-	}, []string{"id", "app_id", "app_display_name", "password_key_id", "password_display_name", "password_end_date_time"})
+	BuildInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "azure_app_exporter_build_info",
+		Help: "Version and commit the exporter was built from, always 1.",
+	}, []string{"version", "commit", "go_version"})
+
+	// The credential metrics below are emitted as const metrics by the applications and service principals
+	// collectors, from the most recent snapshot of their cache. Their labels depend on the layout, so they are built by Init.
+	ApplicationPasswordSeconds                   *prometheus.Desc
+	ApplicationPasswordValid                     *prometheus.Desc
+	ApplicationPasswordAgeSeconds                *prometheus.Desc
+	ApplicationPasswordExpiryTimestampSeconds    *prometheus.Desc
+	ApplicationCertificateSeconds                *prometheus.Desc
+	ApplicationCertificateExpiryTimestampSeconds *prometheus.Desc
+
+	// Only exported with the compact layout, to be joined on the value series for their descriptive labels
+	ApplicationInfo = prometheus.NewDesc(
+		"azure_application_info",
+		"Descriptive labels of the application, always 1.",
+		[]string{"tenant_id", "id", "app_id", "app_display_name"},
+		nil,
+	)
+	ApplicationPasswordInfo = prometheus.NewDesc(
+		"azure_application_password_info",
+		"Descriptive labels of the password credential, always 1.",
+		[]string{"tenant_id", "id", "password_key_id", "password_display_name", "password_end_date_time"},
+		nil,
+	)
+	ApplicationCertificateInfo = prometheus.NewDesc(
+		"azure_application_certificate_info",
+		"Descriptive labels of the certificate (key credential), always 1.",
+		[]string{"tenant_id", "id", "certificate_key_id", "certificate_display_name", "certificate_end_date_time", "certificate_type", "certificate_usage"},
+		nil,
+	)
+
+	ServicePrincipalPasswordSeconds = prometheus.NewDesc(
+		"azure_service_principal_password_remaining_seconds",
+		"Seconds remaining until the service principal password credential expires.",
+		[]string{"tenant_id", "id", "app_id", "service_principal_display_name", "password_key_id", "password_display_name", "password_end_date_time"},
+		nil,
+	)
+	ServicePrincipalCertificateSeconds = prometheus.NewDesc(
+		"azure_service_principal_certificate_remaining_seconds",
+		"Seconds remaining until the service principal certificate (key credential) expires.",
+		[]string{"tenant_id", "id", "app_id", "service_principal_display_name", "certificate_key_id", "certificate_display_name", "certificate_end_date_time"},
+		nil,
+	)
+	ServicePrincipalSamlSigningSeconds = prometheus.NewDesc(
+		"azure_service_principal_saml_signing_remaining_seconds",
+		"Seconds remaining until the service principal preferred SAML token signing certificate expires.",
+		[]string{"tenant_id", "id", "app_id", "service_principal_display_name", "saml_signing_end_date_time"},
+		nil,
+	)
 )
 
+// Count the metrics a collect func emits, e.g. to know how many series a snapshot is exporting
+func CountSeries(collect func(ch chan<- prometheus.Metric)) int {
+	ch := make(chan prometheus.Metric)
+	count := make(chan int)
+
+	go func() {
+		n := 0
+		for range ch {
+			n++
+		}
+		count <- n
+	}()
+
+	collect(ch)
+	close(ch)
+
+	return <-count
+}
+
 func init() {
 	if err := prometheus.Register(TokenSeconds); err != nil {
 		logging.Fatal(err)
@@ -62,7 +204,96 @@ func init() {
 	if err := prometheus.Register(ApplicationsFailures); err != nil {
 		logging.Fatal(err)
 	}
-	if err := prometheus.Register(ApplicationPasswordSeconds); err != nil {
+	if err := prometheus.Register(ServicePrincipalsSeconds); err != nil {
+		logging.Fatal(err)
+	}
+	if err := prometheus.Register(ServicePrincipalsFailures); err != nil {
+		logging.Fatal(err)
+	}
+	if err := prometheus.Register(PrunedSeries); err != nil {
+		logging.Fatal(err)
+	}
+	if err := prometheus.Register(TokenLastSuccess); err != nil {
+		logging.Fatal(err)
+	}
+	if err := prometheus.Register(TokenExpiry); err != nil {
+		logging.Fatal(err)
+	}
+	if err := prometheus.Register(ApplicationsLastSuccess); err != nil {
+		logging.Fatal(err)
+	}
+	if err := prometheus.Register(ApplicationsCached); err != nil {
+		logging.Fatal(err)
+	}
+	if err := prometheus.Register(ApplicationCredentialsCached); err != nil {
+		logging.Fatal(err)
+	}
+	if err := prometheus.Register(RequestRetries); err != nil {
+		logging.Fatal(err)
+	}
+	if err := prometheus.Register(ThrottledResponses); err != nil {
 		logging.Fatal(err)
 	}
+	if err := prometheus.Register(ConfigReloads); err != nil {
+		logging.Fatal(err)
+	}
+	if err := prometheus.Register(ConfigHash); err != nil {
+		logging.Fatal(err)
+	}
+	if err := prometheus.Register(BuildInfo); err != nil {
+		logging.Fatal(err)
+	}
+
+	BuildInfo.WithLabelValues(buildinfo.Version, buildinfo.Commit, runtime.Version()).Set(1)
+
+	// Both results are exported from the start so rate() and increase() work on the first reload
+	ConfigReloads.WithLabelValues("success")
+	ConfigReloads.WithLabelValues("failure")
+}
+
+// Build the metrics that depend on the settings, after globalstate.Init and before the collectors are registered
+func Init() {
+	compactLayout = globalstate.Settings().Metrics.Layout == appsettings.MetricsLayoutCompact
+
+	passwordLabels := layoutLabels([]string{"tenant_id", "id", "password_key_id"}, []string{"tenant_id", "id", "app_id", "app_display_name", "password_key_id", "password_display_name", "password_end_date_time"})
+	certificateLabels := layoutLabels([]string{"tenant_id", "id", "certificate_key_id"}, []string{"tenant_id", "id", "app_id", "app_display_name", "certificate_key_id", "certificate_display_name", "certificate_end_date_time"})
+
+	ApplicationPasswordSeconds = prometheus.NewDesc(
+		"azure_application_password_remaining_seconds",
+		"Seconds remaining until the password credential expires.",
+		passwordLabels,
+		nil,
+	)
+	ApplicationPasswordValid = prometheus.NewDesc(
+		"azure_application_password_valid",
+		"1 if the password credential has started and has not yet expired, 0 otherwise.",
+		passwordLabels,
+		nil,
+	)
+	ApplicationPasswordAgeSeconds = prometheus.NewDesc(
+		"azure_application_password_age_seconds",
+		"Seconds elapsed since the password credential's start date, negative if it starts in the future.",
+		passwordLabels,
+		nil,
+	)
+	ApplicationPasswordExpiryTimestampSeconds = prometheus.NewDesc(
+		"azure_application_password_expiry_timestamp_seconds",
+		"Unix timestamp at which the password credential expires.",
+		passwordLabels,
+		nil,
+	)
+	ApplicationCertificateSeconds = prometheus.NewDesc(
+		"azure_application_certificate_remaining_seconds",
+		"Seconds remaining until the certificate (key credential) expires.",
+		certificateLabels,
+		nil,
+	)
+	ApplicationCertificateExpiryTimestampSeconds = prometheus.NewDesc(
+		"azure_application_certificate_expiry_timestamp_seconds",
+		"Unix timestamp at which the certificate (key credential) expires.",
+		certificateLabels,
+		nil,
+	)
+
+	ConfigHash.WithLabelValues(globalstate.Settings().Hash).Set(1)
 }
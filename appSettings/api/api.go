@@ -33,5 +33,5 @@ import (
 // @success      200  {object}  appsettings.Settings
 // @router       /api/settings [get]
 func ApiSettings(c echo.Context) error {
-	return c.JSON(http.StatusOK, globalstate.Settings)
+	return c.JSON(http.StatusOK, globalstate.Settings())
 }
@@ -21,33 +21,148 @@ package appsettings
 
 import (
 	"azure_app_exporter/logging"
+	"crypto/sha256"
 	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/url"
 	"os"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/pelletier/go-toml/v2"
 )
 
 type Settings struct {
-	Credentials  Credentials  `toml:"credentials"  json:"credentials"  validate:"required" extensions:"x-order=1"`
-	Metrics      Metrics      `toml:"metrics"      json:"metrics"                          extensions:"x-order=2"`
-	Applications Applications `toml:"applications" json:"applications"                     extensions:"x-order=3"`
-	Web          Web          `toml:"web"          json:"web"                              extensions:"x-order=4"`
-	OpenApi      OpenApi      `toml:"openapi"      json:"openapi"                          extensions:"x-order=5"`
-	Tls          Tls          `toml:"tls"          json:"tls"                              extensions:"x-order=6"`
-	Debug        Debug        `toml:"debug"        json:"debug"                            extensions:"x-order=7"`
+	Credentials       Credentials       `toml:"credentials"        json:"credentials"        extensions:"x-order=1"`
+	Tenants           []Tenant          `toml:"tenants"            json:"tenants"            extensions:"x-order=2"`
+	Metrics           Metrics           `toml:"metrics"            json:"metrics"            extensions:"x-order=3"`
+	Applications      Applications      `toml:"applications"       json:"applications"       extensions:"x-order=4"`
+	ServicePrincipals ServicePrincipals `toml:"service_principals" json:"service_principals" extensions:"x-order=5"`
+	Retry             Retry             `toml:"retry"              json:"retry"              extensions:"x-order=6"`
+	Reload            Reload            `toml:"reload"             json:"reload"             extensions:"x-order=7"`
+	Web               Web               `toml:"web"                json:"web"                extensions:"x-order=8"`
+	OpenApi           OpenApi           `toml:"openapi"            json:"openapi"            extensions:"x-order=9"`
+	Tls               Tls               `toml:"tls"                json:"tls"                extensions:"x-order=10"`
+	Logging           Logging           `toml:"logging"            json:"logging"            extensions:"x-order=11"`
+	Tracing           Tracing           `toml:"tracing"            json:"tracing"            extensions:"x-order=12"`
+	Debug             Debug             `toml:"debug"              json:"debug"              extensions:"x-order=13"`
+
+	// SHA-256 of the settings file contents, exported in azure_app_exporter_config_hash_info
+	Hash string `toml:"-" json:"-"`
+	// Where the settings were loaded from, and reloaded from on SIGHUP
+	Path string `toml:"-" json:"-"`
+}
+
+// A single Entra ID tenant monitored by the exporter
+// If no [[tenants]] are configured, the [credentials] header is used as the only tenant
+type Tenant struct {
+	Credentials          Credentials `toml:"credentials"            json:"credentials"            validate:"required" extensions:"x-order=1"`
+	CacheRefreshInterval *Duration   `toml:"cache_refresh_interval" json:"cache_refresh_interval"                     extensions:"x-order=2,x-nullable" swaggertype:"string" example:"30m"`
+}
+
+// Return the tenant's cache refresh interval if it overrides the one from [applications] or [service_principals]
+func (t Tenant) RefreshInterval(fallback Duration) Duration {
+	if t.CacheRefreshInterval != nil {
+		return *t.CacheRefreshInterval
+	}
+
+	return fallback
+}
+
+// The applications API of the tenant's cloud, unless [applications] url is set
+func (t Tenant) ApplicationsUrl(applications Applications) string {
+	if applications.Url != "" {
+		return applications.Url
+	}
+
+	return t.Credentials.ResolvedGraphEndpoint() + "/v1.0/applications"
+}
+
+// The service principals API of the tenant's cloud, unless [service_principals] url is set
+func (t Tenant) ServicePrincipalsUrl(servicePrincipals ServicePrincipals) string {
+	if servicePrincipals.Url != "" {
+		return servicePrincipals.Url
+	}
+
+	return t.Credentials.ResolvedGraphEndpoint() + "/v1.0/servicePrincipals"
 }
 
 type Credentials struct {
-	TenantId     string       `toml:"tenant_id"     json:"tenant_id"     extensions:"x-order=1"`
-	ClientId     string       `toml:"client_id"     json:"client_id"     extensions:"x-order=2"`
-	ClientSecret ClientSecret `toml:"client_secret" json:"client_secret" extensions:"x-order=3"`
+	Mode                    CredentialsMode   `toml:"mode"                      json:"mode"                      extensions:"x-order=1" swaggertype:"string" enums:"client_credentials,managed_identity,workload_identity"`
+	TenantId                string            `toml:"tenant_id"                 json:"tenant_id"                 extensions:"x-order=2"`
+	ClientId                string            `toml:"client_id"                 json:"client_id"                 extensions:"x-order=3"`
+	ClientSecret            ClientSecret      `toml:"client_secret"             json:"client_secret"             extensions:"x-order=4"`
+	ClientSecretFile        *string           `toml:"client_secret_file"        json:"client_secret_file"        extensions:"x-order=5,x-nullable" example:"/run/secrets/azure_app_exporter/client_secret"`
+	ClientSecretEnv         *string           `toml:"client_secret_env"         json:"client_secret_env"         extensions:"x-order=6,x-nullable" example:"AZURE_CLIENT_SECRET"`
+	ClientSecretProvider    *SecretProvider   `toml:"client_secret_provider"    json:"client_secret_provider"    extensions:"x-order=7,x-nullable"`
+	CertificatePath         *string           `toml:"certificate_path"          json:"certificate_path"          extensions:"x-order=8,x-nullable" example:"/etc/azure_app_exporter/client.pem"`
+	KeyPath                 *string           `toml:"key_path"                  json:"key_path"                  extensions:"x-order=9,x-nullable" example:"/etc/azure_app_exporter/client.key"`
+	CertificatePassword     ClientSecret      `toml:"certificate_password"      json:"certificate_password"      extensions:"x-order=10"`
+	CertificateHeader       CertificateHeader `toml:"certificate_header"        json:"certificate_header"        extensions:"x-order=11" swaggertype:"string" enums:"x5t,x5c"`
+	ManagedIdentityEndpoint *string           `toml:"managed_identity_endpoint" json:"managed_identity_endpoint" extensions:"x-order=12,x-nullable" example:"http://169.254.169.254/metadata/identity/oauth2/token"`
+	FederatedTokenFile      *string           `toml:"federated_token_file"      json:"federated_token_file"      extensions:"x-order=13,x-nullable" example:"/var/run/secrets/azure/tokens/azure-identity-token"`
+	Cloud                   Cloud             `toml:"cloud"                     json:"cloud"                     extensions:"x-order=14" swaggertype:"string" enums:"public,usgov,china,custom"`
+	AuthorityHost           *string           `toml:"authority_host"            json:"authority_host"            extensions:"x-order=15,x-nullable" example:"https://login.microsoftonline.com"`
+	GraphEndpoint           *string           `toml:"graph_endpoint"            json:"graph_endpoint"            extensions:"x-order=16,x-nullable" example:"https://graph.microsoft.com"`
+}
+
+// Authenticate with a signed client assertion instead of the client secret
+func (c Credentials) UsesCertificate() bool {
+	return c.CertificatePath != nil
+}
+
+// The client ID of a user-assigned managed identity, or empty for the system-assigned identity
+func (c Credentials) ManagedIdentityClientId() string {
+	if c.ClientId == "..." {
+		return ""
+	}
+
+	return c.ClientId
+}
+
+// The tenant ID used to label metrics and select the tenant in the API
+// It may be empty for a managed identity, which does not need a tenant ID to get a token
+func (c Credentials) ResolvedTenantId() string {
+	if c.Mode == CredentialsModeWorkloadIdentity {
+		tenantId, _, _ := c.WorkloadIdentity()
+		return tenantId
+	}
+
+	if c.TenantId == "..." {
+		return ""
+	}
+
+	return c.TenantId
+}
+
+// Resolve the workload identity settings, falling back to the env vars injected by the Azure workload identity webhook
+// https://azure.github.io/azure-workload-identity/docs/quick-start.html
+func (c Credentials) WorkloadIdentity() (tenantId string, clientId string, tokenFile string) {
+	orEnv := func(value string, envVar string) string {
+		if value != "" && value != "..." {
+			return value
+		}
+		return os.Getenv(envVar)
+	}
+
+	tokenFile = os.Getenv("AZURE_FEDERATED_TOKEN_FILE")
+	if c.FederatedTokenFile != nil {
+		tokenFile = *c.FederatedTokenFile
+	}
+
+	return orEnv(c.TenantId, "AZURE_TENANT_ID"), orEnv(c.ClientId, "AZURE_CLIENT_ID"), tokenFile
+}
+
+func (c Credentials) workloadIdentityAuthorityHost() string {
+	return os.Getenv("AZURE_AUTHORITY_HOST")
 }
 
 type Metrics struct {
-	PruneInterval               *Duration `toml:"prune_interval"                 json:"prune_interval"                 swaggertype:"string" example:"30m" extensions:"x-order=1,x-nullable"`
-	ExpandUnsupportedUrlMetrics bool      `toml:"expand_unsupported_url_metrics" json:"expand_unsupported_url_metrics"                                    extensions:"x-order=2"`
+	PruneInterval               *Duration     `toml:"prune_interval"                 json:"prune_interval"                 swaggertype:"string" example:"30m" extensions:"x-order=1,x-nullable"`
+	ExpandUnsupportedUrlMetrics bool          `toml:"expand_unsupported_url_metrics" json:"expand_unsupported_url_metrics"                                    extensions:"x-order=2"`
+	Layout                      MetricsLayout `toml:"layout"                         json:"layout"                         swaggertype:"string" enums:"legacy,compact" extensions:"x-order=3"`
 }
 
 type Applications struct {
@@ -55,12 +170,37 @@ type Applications struct {
 	CacheRefreshInterval Duration `toml:"cache_refresh_interval" json:"cache_refresh_interval" extensions:"x-order=2" swaggertype:"string" example:"15m"`
 	Url                  string   `toml:"url"                    json:"url"                    extensions:"x-order=2"`
 	ResultsPerPage       uint16   `toml:"results_per_page"       json:"results_per_page"       extensions:"x-order=3"                                    minimum:"1" maximum:"999"`
+	SyncMode             SyncMode `toml:"sync_mode"              json:"sync_mode"              extensions:"x-order=4" swaggertype:"string" enums:"full,delta"`
+	FullResyncInterval   Duration `toml:"full_resync_interval"   json:"full_resync_interval"   extensions:"x-order=5" swaggertype:"string" example:"24h"`
+	CacheFile            *string  `toml:"cache_file"             json:"cache_file"             extensions:"x-order=6,x-nullable" example:"/var/lib/azure_app_exporter/applications.json"`
+}
+
+type ServicePrincipals struct {
+	Enabled              bool     `toml:"enabled"                json:"enabled"                extensions:"x-order=1"`
+	CacheRefreshInterval Duration `toml:"cache_refresh_interval" json:"cache_refresh_interval" extensions:"x-order=2" swaggertype:"string" example:"15m"`
+	Url                  string   `toml:"url"                    json:"url"                    extensions:"x-order=3"`
+	ResultsPerPage       uint16   `toml:"results_per_page"       json:"results_per_page"       extensions:"x-order=4"                                    minimum:"1" maximum:"999"`
+}
+
+// Applies to every request to the login and Graph endpoints
+type Retry struct {
+	MaxAttempts    int      `toml:"max_attempts"    json:"max_attempts"    extensions:"x-order=1"`
+	InitialBackoff Duration `toml:"initial_backoff" json:"initial_backoff" extensions:"x-order=2" swaggertype:"string" example:"1s"`
+	MaxBackoff     Duration `toml:"max_backoff"     json:"max_backoff"     extensions:"x-order=3" swaggertype:"string" example:"1m"`
+}
+
+// The settings file is always reloaded on SIGHUP
+type Reload struct {
+	WatchFile     bool     `toml:"watch_file"     json:"watch_file"     extensions:"x-order=1"`
+	WatchInterval Duration `toml:"watch_interval" json:"watch_interval" extensions:"x-order=2" swaggertype:"string" example:"10s"`
 }
 
 type Web struct {
-	ListenAddress string  `toml:"listen_address" json:"listen_address" extensions:"x-order=1"`
-	CertFile      *string `toml:"cert_file"      json:"cert_file"      extensions:"x-order=2,x-nullable"`
-	KeyFile       *string `toml:"key_file"       json:"key_file"       extensions:"x-order=3,x-nullable"`
+	ListenAddress        string   `toml:"listen_address"          json:"listen_address"          extensions:"x-order=1"`
+	CertFile             *string  `toml:"cert_file"               json:"cert_file"               extensions:"x-order=2,x-nullable" example:"/etc/azure_app_exporter/cert.pem"`
+	KeyFile              *string  `toml:"key_file"                json:"key_file"                extensions:"x-order=3,x-nullable" example:"/etc/azure_app_exporter/key.pem"`
+	ReadinessMaxCacheAge Duration `toml:"readiness_max_cache_age" json:"readiness_max_cache_age" extensions:"x-order=4" swaggertype:"string" example:"1h"`
+	ShutdownTimeout      Duration `toml:"shutdown_timeout"        json:"shutdown_timeout"        extensions:"x-order=5" swaggertype:"string" example:"30s"`
 }
 
 type OpenApi struct {
@@ -85,34 +225,92 @@ func (t Tls) ToCipherSuites() []uint16 {
 	return cipherSuites
 }
 
+type Logging struct {
+	Level  LogLevel  `toml:"level"  json:"level"  extensions:"x-order=1" swaggertype:"string" enums:"debug,info,warn,error,off"`
+	Format LogFormat `toml:"format" json:"format" extensions:"x-order=2" swaggertype:"string" enums:"text,json"`
+	Output string    `toml:"output" json:"output" extensions:"x-order=3" example:"stderr"`
+}
+
+// The logging package's options for these settings
+func (l Logging) Options() logging.Options {
+	level, _ := logging.ParseLevel(string(l.Level))
+
+	return logging.Options{
+		Level:  level,
+		Json:   l.Format == LogFormatJson,
+		Output: l.Output,
+	}
+}
+
+type Tracing struct {
+	Enabled  bool            `toml:"enabled"  json:"enabled"  extensions:"x-order=1"`
+	Protocol TracingProtocol `toml:"protocol" json:"protocol" extensions:"x-order=2" swaggertype:"string" enums:"http,grpc"`
+	// host:port of the collector, empty uses OTEL_EXPORTER_OTLP_ENDPOINT or the exporter's default
+	Endpoint    string  `toml:"endpoint"     json:"endpoint"     extensions:"x-order=3" example:"otel-collector:4318"`
+	Insecure    bool    `toml:"insecure"     json:"insecure"     extensions:"x-order=4"`
+	SampleRatio float64 `toml:"sample_ratio" json:"sample_ratio" extensions:"x-order=5"`
+	ServiceName string  `toml:"service_name" json:"service_name" extensions:"x-order=6"`
+}
+
 type Debug struct {
 	NoVerifyTls bool `toml:"no_verify_tls" json:"no_verify_tls"`
 }
 
-func Parse() Settings {
+// Return the path of the settings file from the AZURE_APP_EXPORTER_SETTINGS_PATH env var, or the default one
+func SettingsPath() string {
 	settingsEnvVar := "AZURE_APP_EXPORTER_SETTINGS_PATH"
 	settingsPath := "/etc/azure_app_exporter/settings.toml"
 
 	if path, ok := os.LookupEnv(settingsEnvVar); ok {
-		settingsPath = path
-	} else {
-		logging.Warnf("no %s env var set, defaulting to %s", settingsEnvVar, settingsPath)
+		return path
 	}
 
-	settingsContents, err := os.ReadFile(settingsPath)
-	if err != nil {
-		logging.Fatalf("failed reading %s -> %s", settingsPath, err)
-	}
+	logging.With("component", "settings").Warnf("no %s env var set, defaulting to %s", settingsEnvVar, settingsPath)
+
+	return settingsPath
+}
 
-	settings := Settings{
+// Return the hex SHA-256 of the settings file contents, used to tell whether the file changed
+func ContentHash(contents []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(contents))
+}
+
+// The value of every setting that is not provided
+func Defaults() Settings {
+	return Settings{
+		Credentials: Credentials{
+			Mode:              CredentialsModeClientCredentials,
+			CertificateHeader: CertificateHeaderX5t,
+			Cloud:             CloudPublic,
+		},
+		Metrics: Metrics{
+			Layout: MetricsLayoutLegacy,
+		},
 		Applications: Applications{
 			Enabled:              true,
 			CacheRefreshInterval: Duration{15 * time.Minute},
-			Url:                  "https://graph.microsoft.com/v1.0/applications",
 			ResultsPerPage:       999,
+			SyncMode:             SyncModeFull,
+			FullResyncInterval:   Duration{24 * time.Hour},
+		},
+		ServicePrincipals: ServicePrincipals{
+			Enabled:              false,
+			CacheRefreshInterval: Duration{15 * time.Minute},
+			ResultsPerPage:       999,
+		},
+		Retry: Retry{
+			MaxAttempts:    5,
+			InitialBackoff: Duration{time.Second},
+			MaxBackoff:     Duration{time.Minute},
+		},
+		Reload: Reload{
+			WatchFile:     false,
+			WatchInterval: Duration{10 * time.Second},
 		},
 		Web: Web{
-			ListenAddress: "0.0.0.0:9081",
+			ListenAddress:        "0.0.0.0:9081",
+			ReadinessMaxCacheAge: Duration{time.Hour},
+			ShutdownTimeout:      Duration{30 * time.Second},
 		},
 		OpenApi: OpenApi{
 			Enabled:      true,
@@ -136,46 +334,300 @@ func Parse() Settings {
 				ProtocolVersion(tls.VersionTLS12),
 			},
 		},
+		Logging: Logging{
+			Level:  LogLevelInfo,
+			Format: LogFormatText,
+			Output: "stderr",
+		},
+		Tracing: Tracing{
+			Enabled:     false,
+			Protocol:    TracingProtocolHttp,
+			SampleRatio: 1,
+			ServiceName: "azure_app_exporter",
+		},
+	}
+}
+
+// Settings forced from the command line, which win over the file and the env vars on every load
+type Overrides struct {
+	LogLevel *LogLevel
+}
+
+func (o Overrides) Apply(s *Settings) {
+	if o.LogLevel != nil {
+		s.Logging.Level = *o.LogLevel
+	}
+}
+
+// Read, parse and validate the settings file at settingsPath, with the env var overrides applied on top
+func Load(settingsPath string) (Settings, error) {
+	// A missing file is fine as long as env vars provide the settings, which is checked below
+	settingsContents, err := os.ReadFile(settingsPath)
+	fileMissing := errors.Is(err, os.ErrNotExist)
+	if err != nil && !fileMissing {
+		return Settings{}, fmt.Errorf("failed reading %s -> %w", settingsPath, err)
 	}
 
+	settings := Defaults()
+
 	if err := toml.Unmarshal(settingsContents, &settings); err != nil {
-		logging.Fatalf("failed parsing %s -> %s", settingsPath, err)
+		return Settings{}, fmt.Errorf("failed parsing %s -> %w", settingsPath, err)
+	}
+
+	overrides, err := applyEnvOverrides(&settings)
+	if err != nil {
+		return Settings{}, err
+	}
+	if fileMissing && overrides == 0 {
+		return Settings{}, fmt.Errorf("settings file %s does not exist and no %s* env vars are set", settingsPath, envPrefix)
+	}
+
+	// Only the file is hashed, env vars can't change while the exporter runs
+	settings.Hash = ContentHash(settingsContents)
+	settings.Path = settingsPath
+
+	if len(settings.Tenants) == 0 {
+		settings.Tenants = []Tenant{{Credentials: settings.Credentials}}
+	} else {
+		// Array tables are decoded into zero values, so the credential defaults have to be filled in afterwards
+		for i := range settings.Tenants {
+			if settings.Tenants[i].Credentials.Mode == "" {
+				settings.Tenants[i].Credentials.Mode = CredentialsModeClientCredentials
+			}
+			if settings.Tenants[i].Credentials.CertificateHeader == "" {
+				settings.Tenants[i].Credentials.CertificateHeader = CertificateHeaderX5t
+			}
+			if settings.Tenants[i].Credentials.Cloud == "" {
+				settings.Tenants[i].Credentials.Cloud = CloudPublic
+			}
+		}
+	}
+
+	for _, tenant := range settings.Tenants {
+		if tenant.Credentials.ClientSecretProvider != nil {
+			tenant.Credentials.ClientSecretProvider.setDefaults()
+		}
 	}
 
 	sort.Slice(settings.Tls.ProtocolVersions, func(i, j int) bool {
 		return settings.Tls.ProtocolVersions[i] < settings.Tls.ProtocolVersions[j]
 	})
 
-	validate(settings)
+	if err := validate(settings); err != nil {
+		return Settings{}, fmt.Errorf("invalid settings in %s -> %w", settingsPath, err)
+	}
 
-	return settings
+	return settings, nil
 }
 
-func validate(s Settings) {
+// Return every problem found in the settings, joined into a single error
+func validate(s Settings) error {
+	var errs []error
+
 	if s.Applications.ResultsPerPage < 1 || s.Applications.ResultsPerPage > 999 {
-		logging.Fatalf("settings value %d not in range 1..=999", s.Applications.ResultsPerPage)
+		errs = append(errs, fmt.Errorf("settings value applications.results_per_page %d not in range 1..=999", s.Applications.ResultsPerPage))
+	}
+
+	if s.ServicePrincipals.ResultsPerPage < 1 || s.ServicePrincipals.ResultsPerPage > 999 {
+		errs = append(errs, fmt.Errorf("settings value service_principals.results_per_page %d not in range 1..=999", s.ServicePrincipals.ResultsPerPage))
+	}
+
+	if s.Retry.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("settings value retry.max_attempts %d must be at least 1", s.Retry.MaxAttempts))
+	}
+
+	if s.Retry.InitialBackoff.Duration <= 0 {
+		errs = append(errs, fmt.Errorf("settings value retry.initial_backoff %s must be positive", s.Retry.InitialBackoff))
+	}
+
+	if s.Retry.MaxBackoff.Duration <= 0 {
+		errs = append(errs, fmt.Errorf("settings value retry.max_backoff %s must be positive", s.Retry.MaxBackoff))
+	}
+
+	if s.Retry.InitialBackoff.Duration > s.Retry.MaxBackoff.Duration {
+		errs = append(errs, fmt.Errorf("settings value retry.initial_backoff %s cannot exceed retry.max_backoff %s", s.Retry.InitialBackoff, s.Retry.MaxBackoff))
+	}
+
+	if s.Reload.WatchFile && s.Reload.WatchInterval.Duration <= 0 {
+		errs = append(errs, fmt.Errorf("settings value reload.watch_interval %s must be positive", s.Reload.WatchInterval))
+	}
+
+	// /readyz would fail in every cycle until the next refresh finishes
+	if maxInterval := s.maxRefreshInterval(); maxInterval > 0 && s.Web.ReadinessMaxCacheAge.Duration <= maxInterval {
+		errs = append(errs, fmt.Errorf("settings value web.readiness_max_cache_age %s must be greater than the largest cache_refresh_interval %s", s.Web.ReadinessMaxCacheAge, maxInterval))
+	}
+
+	if s.Tracing.SampleRatio < 0 || s.Tracing.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("settings value tracing.sample_ratio %g not in range 0..=1", s.Tracing.SampleRatio))
 	}
 
 	if len(s.Tls.ProtocolVersions) < 1 {
-		logging.Fatal("tls protocol versions cannot be empty")
+		errs = append(errs, errors.New("tls protocol versions cannot be empty"))
+	}
+
+	errs = append(errs, checkUrl(s.OpenApi.DocsUrl), checkUrl(s.OpenApi.SwaggerUiUrl))
+
+	tenantIds := make(map[string]struct{}, len(s.Tenants))
+
+	for i, tenant := range s.Tenants {
+		prefix := "credentials."
+		if len(s.Tenants) > 1 {
+			prefix = fmt.Sprintf("tenants[%d].credentials.", i)
+		}
+
+		errs = append(errs, validateCredentials(prefix, tenant.Credentials))
+
+		tenantId := tenant.Credentials.ResolvedTenantId()
+		if tenantId == "" && len(s.Tenants) > 1 {
+			errs = append(errs, fmt.Errorf("empty credential found in settings.toml: %stenant_id is required when monitoring multiple tenants", prefix))
+		}
+		if _, duplicate := tenantIds[tenantId]; duplicate {
+			errs = append(errs, fmt.Errorf("tenant_id %q is configured more than once in settings.toml", tenantId))
+		}
+		tenantIds[tenantId] = struct{}{}
+
+		// An explicit API url has to point at the same cloud the token is minted for
+		checkSameHost := func(name string, apiUrl string) error {
+			graphEndpoint := tenant.Credentials.ResolvedGraphEndpoint()
+			if apiHost, graphHost := hostOf(apiUrl), hostOf(graphEndpoint); apiHost != graphHost {
+				return fmt.Errorf("%s %s does not match the graph endpoint %s of tenant %q", name, apiUrl, graphEndpoint, tenantId)
+			}
+			return nil
+		}
+
+		// A leftover url of a disabled section is never called
+		if s.Applications.Enabled {
+			errs = append(errs, checkSameHost("applications.url", tenant.ApplicationsUrl(s.Applications)))
+		}
+		if s.ServicePrincipals.Enabled {
+			errs = append(errs, checkSameHost("service_principals.url", tenant.ServicePrincipalsUrl(s.ServicePrincipals)))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// The longest refresh interval of any tenant among the enabled caches, which /readyz checks
+func (s Settings) maxRefreshInterval() time.Duration {
+	var maxInterval time.Duration
+
+	for _, tenant := range s.Tenants {
+		if s.Applications.Enabled {
+			maxInterval = max(maxInterval, tenant.RefreshInterval(s.Applications.CacheRefreshInterval).Duration)
+		}
+		if s.ServicePrincipals.Enabled {
+			maxInterval = max(maxInterval, tenant.RefreshInterval(s.ServicePrincipals.CacheRefreshInterval).Duration)
+		}
 	}
 
-	checkUrl := func(url string) {
-		if url == "" || url == "/" {
-			logging.Fatalf("url %s cannot be empty or \"/\"", url)
+	return maxInterval
+}
+
+func hostOf(rawUrl string) string {
+	if parsed, err := url.Parse(rawUrl); err == nil {
+		return strings.ToLower(parsed.Host)
+	}
+
+	return ""
+}
+
+func checkUrl(url string) error {
+	if url == "" || url == "/" {
+		return fmt.Errorf("url %s cannot be empty or \"/\"", url)
+	}
+
+	return nil
+}
+
+func validateCredentials(prefix string, c Credentials) error {
+	var errs []error
+
+	credentialPresent := func(credential string) bool {
+		return credential != "" && credential != "..."
+	}
+
+	verifyCredentialPresent := func(name string, credential string) {
+		if !credentialPresent(credential) {
+			errs = append(errs, fmt.Errorf("empty credential found in settings.toml: %s", name))
 		}
 	}
 
-	checkUrl(s.OpenApi.DocsUrl)
-	checkUrl(s.OpenApi.SwaggerUiUrl)
+	// Every place the client secret can come from, of which client_credentials needs exactly one
+	var secretSources []string
+	if credentialPresent(string(c.ClientSecret)) {
+		secretSources = append(secretSources, "client_secret")
+	}
+	if c.ClientSecretFile != nil {
+		secretSources = append(secretSources, "client_secret_file")
+	}
+	if c.ClientSecretEnv != nil {
+		secretSources = append(secretSources, "client_secret_env")
+	}
+	if c.ClientSecretProvider != nil {
+		secretSources = append(secretSources, "client_secret_provider")
+	}
+
+	switch c.Mode {
+	case CredentialsModeClientCredentials:
+		verifyCredentialPresent(prefix+"tenant_id", c.TenantId)
+		verifyCredentialPresent(prefix+"client_id", c.ClientId)
+
+		if c.UsesCertificate() {
+			verifyCredentialPresent(prefix+"certificate_path", *c.CertificatePath)
+
+			if len(secretSources) > 0 {
+				errs = append(errs, fmt.Errorf("%s%s and %scertificate_path cannot both be set in settings.toml", prefix, secretSources[0], prefix))
+			}
+		} else if len(secretSources) == 0 {
+			errs = append(errs, fmt.Errorf("empty credential found in settings.toml: %sclient_secret, or one of client_secret_file, client_secret_env and client_secret_provider", prefix))
+		} else if len(secretSources) > 1 {
+			errs = append(errs, fmt.Errorf("only one of %s%s can be set in settings.toml", prefix, strings.Join(secretSources, ", "+prefix)))
+		}
+
+		if c.ClientSecretProvider != nil {
+			errs = append(errs, validateSecretProvider(prefix+"client_secret_provider.", *c.ClientSecretProvider))
+		}
+	case CredentialsModeManagedIdentity:
+		// client_id is optional and selects a user-assigned identity
+		if len(secretSources) > 0 || c.UsesCertificate() {
+			errs = append(errs, fmt.Errorf("%sclient_secret and %scertificate_path cannot be set with credentials mode %s", prefix, prefix, c.Mode))
+		}
+
+		if c.ManagedIdentityEndpoint != nil {
+			errs = append(errs, checkUrl(*c.ManagedIdentityEndpoint))
+		}
+	case CredentialsModeWorkloadIdentity:
+		if len(secretSources) > 0 || c.UsesCertificate() {
+			errs = append(errs, fmt.Errorf("%sclient_secret and %scertificate_path cannot be set with credentials mode %s", prefix, prefix, c.Mode))
+		}
+
+		tenantId, clientId, tokenFile := c.WorkloadIdentity()
+		verifyCredentialPresent(prefix+"tenant_id or AZURE_TENANT_ID", tenantId)
+		verifyCredentialPresent(prefix+"client_id or AZURE_CLIENT_ID", clientId)
+		verifyCredentialPresent(prefix+"federated_token_file or AZURE_FEDERATED_TOKEN_FILE", tokenFile)
+	}
+
+	if c.AuthorityHost != nil {
+		errs = append(errs, checkUrl(*c.AuthorityHost))
+	}
+	if c.GraphEndpoint != nil {
+		errs = append(errs, checkUrl(*c.GraphEndpoint))
+	}
 
-	verifyCredentialPresent := func(credential string) {
-		if credential == "" || credential == "..." {
-			logging.Fatalf("empty credential found in settings.toml: %s", credential)
+	if c.Cloud == CloudCustom {
+		if c.AuthorityHost == nil || c.GraphEndpoint == nil {
+			errs = append(errs, fmt.Errorf("%sauthority_host and %sgraph_endpoint are required with cloud %s", prefix, prefix, c.Cloud))
+		}
+	} else {
+		// Overrides are only allowed to restate the endpoints of a known cloud, anything else needs cloud = "custom"
+		endpoints := cloudEndpointsValue[c.Cloud]
+		if authorityHost := c.ResolvedAuthorityHost(); !strings.EqualFold(authorityHost, endpoints.authorityHost) {
+			errs = append(errs, fmt.Errorf("%sauthority_host %s does not match cloud %s, expected %s or cloud \"custom\"", prefix, authorityHost, c.Cloud, endpoints.authorityHost))
+		}
+		if graphEndpoint := c.ResolvedGraphEndpoint(); !strings.EqualFold(graphEndpoint, endpoints.graphEndpoint) {
+			errs = append(errs, fmt.Errorf("%sgraph_endpoint %s does not match cloud %s, expected %s or cloud \"custom\"", prefix, graphEndpoint, c.Cloud, endpoints.graphEndpoint))
 		}
 	}
 
-	verifyCredentialPresent(s.Credentials.TenantId)
-	verifyCredentialPresent(s.Credentials.ClientId)
-	verifyCredentialPresent(string(s.Credentials.ClientSecret))
+	return errors.Join(errs...)
 }
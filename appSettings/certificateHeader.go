@@ -0,0 +1,52 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package appsettings
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Which header identifies the certificate in a signed client assertion
+// https://learn.microsoft.com/en-us/entra/identity-platform/certificate-credentials#header
+type CertificateHeader string
+
+const (
+	// Only send the certificate thumbprint
+	CertificateHeaderX5t CertificateHeader = "x5t"
+	// Send the certificate thumbprint and the certificate chain, required for subject name + issuer authentication
+	CertificateHeaderX5c CertificateHeader = "x5c"
+)
+
+var certificateHeaderValue = map[string]CertificateHeader{
+	string(CertificateHeaderX5t): CertificateHeaderX5t,
+	string(CertificateHeaderX5c): CertificateHeaderX5c,
+}
+
+func (c *CertificateHeader) UnmarshalText(bytes []byte) error {
+	name := string(bytes)
+
+	if certificateHeader, ok := certificateHeaderValue[name]; ok {
+		*c = certificateHeader
+		return nil
+	}
+
+	return fmt.Errorf("invalid certificate header %s, expected one of %v", name, reflect.ValueOf(certificateHeaderValue).MapKeys())
+}
@@ -0,0 +1,97 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package appsettings
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// The Azure cloud the tenant lives in, which decides the login and Microsoft Graph endpoints
+// https://learn.microsoft.com/en-us/graph/deployments#app-registration-and-token-service-root-endpoints
+type Cloud string
+
+const (
+	CloudPublic Cloud = "public"
+	CloudUsGov  Cloud = "usgov"
+	CloudChina  Cloud = "china"
+	// Both authority_host and graph_endpoint have to be set explicitly
+	CloudCustom Cloud = "custom"
+)
+
+type cloudEndpoints struct {
+	authorityHost string
+	graphEndpoint string
+}
+
+var cloudValue = map[string]Cloud{
+	string(CloudPublic): CloudPublic,
+	string(CloudUsGov):  CloudUsGov,
+	string(CloudChina):  CloudChina,
+	string(CloudCustom): CloudCustom,
+}
+
+var cloudEndpointsValue = map[Cloud]cloudEndpoints{
+	CloudPublic: {authorityHost: "https://login.microsoftonline.com", graphEndpoint: "https://graph.microsoft.com"},
+	CloudUsGov:  {authorityHost: "https://login.microsoftonline.us", graphEndpoint: "https://graph.microsoft.us"},
+	CloudChina:  {authorityHost: "https://login.chinacloudapi.cn", graphEndpoint: "https://microsoftgraph.chinacloudapi.cn"},
+}
+
+func (c *Cloud) UnmarshalText(bytes []byte) error {
+	name := string(bytes)
+
+	if cloud, ok := cloudValue[name]; ok {
+		*c = cloud
+		return nil
+	}
+
+	return fmt.Errorf("invalid cloud %s, expected one of %v", name, reflect.ValueOf(cloudValue).MapKeys())
+}
+
+// The login endpoint without a trailing slash, e.g. https://login.microsoftonline.com
+// Workload identity falls back to the AZURE_AUTHORITY_HOST env var injected by its webhook
+func (c Credentials) ResolvedAuthorityHost() string {
+	if c.AuthorityHost != nil {
+		return strings.TrimRight(*c.AuthorityHost, "/")
+	}
+
+	if c.Mode == CredentialsModeWorkloadIdentity {
+		if host := c.workloadIdentityAuthorityHost(); host != "" {
+			return strings.TrimRight(host, "/")
+		}
+	}
+
+	return cloudEndpointsValue[c.Cloud].authorityHost
+}
+
+// The Microsoft Graph endpoint without a trailing slash, e.g. https://graph.microsoft.com
+func (c Credentials) ResolvedGraphEndpoint() string {
+	if c.GraphEndpoint != nil {
+		return strings.TrimRight(*c.GraphEndpoint, "/")
+	}
+
+	return cloudEndpointsValue[c.Cloud].graphEndpoint
+}
+
+// The scope requested in the client credentials grant
+func (c Credentials) GraphScope() string {
+	return c.ResolvedGraphEndpoint() + "/.default"
+}
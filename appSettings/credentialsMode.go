@@ -0,0 +1,54 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package appsettings
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// How the exporter authenticates to get its own Graph token
+type CredentialsMode string
+
+const (
+	// OAuth2 client credentials grant with a client secret or a certificate
+	CredentialsModeClientCredentials CredentialsMode = "client_credentials"
+	// Azure managed identity through the instance metadata service or the App Service identity endpoint
+	CredentialsModeManagedIdentity CredentialsMode = "managed_identity"
+	// Azure workload identity federation, exchanging a projected service account token for a Graph token
+	CredentialsModeWorkloadIdentity CredentialsMode = "workload_identity"
+)
+
+var credentialsModeValue = map[string]CredentialsMode{
+	string(CredentialsModeClientCredentials): CredentialsModeClientCredentials,
+	string(CredentialsModeManagedIdentity):   CredentialsModeManagedIdentity,
+	string(CredentialsModeWorkloadIdentity):  CredentialsModeWorkloadIdentity,
+}
+
+func (c *CredentialsMode) UnmarshalText(bytes []byte) error {
+	name := string(bytes)
+
+	if credentialsMode, ok := credentialsModeValue[name]; ok {
+		*c = credentialsMode
+		return nil
+	}
+
+	return fmt.Errorf("invalid credentials mode %s, expected one of %v", name, reflect.ValueOf(credentialsModeValue).MapKeys())
+}
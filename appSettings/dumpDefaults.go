@@ -0,0 +1,295 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package appsettings
+
+import (
+	"encoding"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Comments written by DumpDefaults, keyed by section or "section.key". See settings_template.toml for the long form.
+var settingDocs = map[string]string{
+	"credentials": "Credentials the exporter uses to get its own Graph token",
+	"credentials.mode": `How the exporter authenticates, one of:
+"client_credentials" - tenant_id, client_id and either client_secret or certificate_path are required
+"managed_identity"   - the managed identity of the VM, container or app service, client_id selects a user-assigned one
+"workload_identity"  - the AKS workload identity, tenant_id and client_id fall back to AZURE_TENANT_ID and AZURE_CLIENT_ID`,
+	"credentials.tenant_id":                 "The tenant to monitor",
+	"credentials.client_id":                 "The client id of the service principal, or of a user-assigned managed identity",
+	"credentials.client_secret":             "Leave empty when certificate_path is set",
+	"credentials.certificate_path":          "Authenticate with a certificate instead of the client secret, a PEM file or a PKCS#12 archive (.pfx/.p12)",
+	"credentials.key_path":                  "The private key of certificate_path, if it is not in the same file",
+	"credentials.certificate_password":      "The password of a PKCS#12 certificate_path",
+	"credentials.certificate_header":        `Which header identifies the certificate in the client assertion, "x5t" (thumbprint) or "x5c" (thumbprint and chain)`,
+	"credentials.managed_identity_endpoint": "Override the managed identity token endpoint, IDENTITY_ENDPOINT or the instance metadata endpoint by default",
+	"credentials.federated_token_file":      `The projected service account token of mode "workload_identity", AZURE_FEDERATED_TOKEN_FILE by default`,
+	"credentials.cloud":                     `The Azure cloud of the tenant, one of "public", "usgov", "china" or "custom"`,
+	"credentials.authority_host":            `Override the login endpoint, required with cloud "custom"`,
+	"credentials.graph_endpoint":            `Override the Graph endpoint, required with cloud "custom"`,
+	"credentials.client_secret_file":        "Read the client secret from this file instead, on every token refresh",
+	"credentials.client_secret_env":         "Read the client secret from this env var instead, on every token refresh",
+	"credentials.client_secret_provider": `Fetch the client secret from Azure Key Vault or HashiCorp Vault instead, on every token refresh.
+Only one of client_secret, client_secret_file, client_secret_env and client_secret_provider can be set.`,
+	"credentials.client_secret_provider.type":               `"azure_key_vault" or "hashicorp_vault"`,
+	"credentials.client_secret_provider.vault_url":          "Azure Key Vault: the vault holding the secret",
+	"credentials.client_secret_provider.secret_name":        "Azure Key Vault: the name of the secret",
+	"credentials.client_secret_provider.secret_version":     "Azure Key Vault: pin a version of the secret, the latest one by default",
+	"credentials.client_secret_provider.identity":           `Azure Key Vault: authenticate with "managed_identity" (default) or "workload_identity"`,
+	"credentials.client_secret_provider.identity_client_id": "Azure Key Vault: the client id of a user-assigned managed identity or of the workload identity",
+	"credentials.client_secret_provider.address":            "HashiCorp Vault: the server, VAULT_ADDR by default",
+	"credentials.client_secret_provider.path":               "HashiCorp Vault: the path of a KV v1 or v2 secret, e.g. secret/data/azure_app_exporter for KV v2",
+	"credentials.client_secret_provider.key":                `HashiCorp Vault: the key of the secret holding the client secret, default "client_secret"`,
+	"credentials.client_secret_provider.token_file":         "HashiCorp Vault: read the token from this file on every refresh, VAULT_TOKEN by default",
+	"credentials.client_secret_provider.namespace":          "HashiCorp Vault Enterprise: the namespace of the secret",
+
+	"tenants": `To monitor multiple tenants, configure one [[tenants]] entry per tenant instead of [credentials].
+[credentials] is ignored when at least one [[tenants]] entry is present.`,
+	"tenants.cache_refresh_interval": "Override the cache_refresh_interval from [applications] and [service_principals] for this tenant",
+	"tenants.credentials":            "Accepts the same settings as [credentials], tenant_id is required",
+
+	"metrics.prune_interval":                 "Stop exporting the metrics of cached applications and service principals once they are older than this, never if not set",
+	"metrics.expand_unsupported_url_metrics": `Show the full URL of requests on unsupported URLs in metrics instead of "unsupported-url"`,
+	"metrics.layout":                         `Which labels the application credential metrics carry, "legacy" or "compact". Requires a restart.`,
+
+	"applications.enabled":                "Enable monitoring Azure applications",
+	"applications.cache_refresh_interval": "How often to refresh the in-memory cache of Azure applications",
+	"applications.url":                    "The URL to the applications API, {graph_endpoint}/v1.0/applications if empty",
+	"applications.results_per_page":       "How many applications to include per API response page, 1-999 inclusive",
+	"applications.sync_mode":              `How the cached applications are refreshed, "full" lists all of them, "delta" only fetches the changes`,
+	"applications.full_resync_interval":   `With sync_mode "delta", crawl the whole delta query again after this span of time`,
+	"applications.cache_file":             "Save a snapshot of the cached applications to this file and load it at startup",
+
+	"service_principals.enabled":                "Enable monitoring Azure service principals (enterprise applications)",
+	"service_principals.cache_refresh_interval": "How often to refresh the in-memory cache of Azure service principals",
+	"service_principals.url":                    "The URL to the service principals API, {graph_endpoint}/v1.0/servicePrincipals if empty",
+	"service_principals.results_per_page":       "How many service principals to include per API response page, 1-999 inclusive",
+
+	"retry":                 "Retry policy for requests to the login and Graph endpoints that are throttled with HTTP 429 or 503",
+	"retry.max_attempts":    "How many times a request is sent in total before giving up, 1 disables retries",
+	"retry.initial_backoff": "Delay before the first retry, doubled on every following one, unless Azure sends Retry-After",
+	"retry.max_backoff":     "Upper bound of the delay between retries, a longer Retry-After is not retried",
+
+	"reload":                "The settings file is always reloaded on SIGHUP",
+	"reload.watch_file":     "Also reload the settings file whenever its contents change",
+	"reload.watch_interval": "How often the file is checked for changes when watch_file is enabled",
+
+	"web.listen_address":          "The address the server listens on",
+	"web.cert_file":               "Serve HTTPS if both cert_file and key_file are set, HTTP otherwise",
+	"web.key_file":                "The private key of cert_file",
+	"web.readiness_max_cache_age": "/readyz fails once the cached applications or service principals of any tenant are older than this, must be greater than the largest cache_refresh_interval",
+	"web.shutdown_timeout":        "On SIGINT or SIGTERM, how long to wait for in-flight requests to finish",
+
+	"openapi.enabled":        "Enables both the OpenAPI json docs and Swagger UI",
+	"openapi.docs_url":       `Cannot be empty or "/"`,
+	"openapi.swagger_ui_url": `Cannot be empty or "/"`,
+
+	"tls":                   "Allowed TLS settings of the server, remove values you want to disable",
+	"tls.cipher_suites":     "TLS1.3 suites are not configurable in Go",
+	"tls.protocol_versions": `"TLS13" and "TLS12"`,
+
+	"logging.level":  `One of "debug", "info", "warn", "error" or "off", the -log-level flag overrides it`,
+	"logging.format": `"text" for logfmt style key=value pairs or "json" for one object per line`,
+	"logging.output": `"stderr", "stdout" or the path of a file to append to`,
+
+	"tracing":              "OpenTelemetry spans of the server, the token updater and every Graph page, exported over OTLP",
+	"tracing.protocol":     `"http" for OTLP/HTTP or "grpc" for OTLP/gRPC`,
+	"tracing.endpoint":     "host:port of the collector, empty uses OTEL_EXPORTER_OTLP_ENDPOINT or localhost with the protocol's default port",
+	"tracing.insecure":     "Connect to the collector without TLS",
+	"tracing.sample_ratio": "Share of the traces to record, between 0.0 and 1.0. Traces started upstream keep their sampling decision",
+
+	"debug.no_verify_tls": "Do not verify certificates when making requests to external APIs",
+}
+
+// Write a settings file with the default value of every setting and a comment on each
+func DumpDefaults(w io.Writer) error {
+	var out strings.Builder
+
+	out.WriteString("# Every setting can be overridden by an env var named after its section and key,\n")
+	out.WriteString("# e.g. AZURE_APP_EXPORTER_CREDENTIALS__CLIENT_SECRET or AZURE_APP_EXPORTER_TENANTS__0__CREDENTIALS__TENANT_ID\n")
+
+	defaults := Defaults()
+	settings := reflect.ValueOf(&defaults).Elem()
+
+	for i := 0; i < settings.NumField(); i++ {
+		section := settings.Field(i)
+		name := tomlName(settings.Type().Field(i))
+		if name == "" {
+			continue
+		}
+
+		out.WriteString("\n")
+
+		// Arrays of tables have no default, an example entry is written commented out instead
+		if section.Kind() == reflect.Slice {
+			writeComment(&out, settingDocs[name])
+			out.WriteString("# [[" + name + "]]\n")
+			if err := writeTable(&out, "# ", name, reflect.New(section.Type().Elem()).Elem()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		writeComment(&out, settingDocs[name])
+		out.WriteString("[" + name + "]\n")
+		if err := writeTable(&out, "", name, section); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+func tomlName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("toml"), ",")
+	if name == "-" {
+		return ""
+	}
+
+	return name
+}
+
+func writeComment(out *strings.Builder, comment string) {
+	if comment == "" {
+		return
+	}
+
+	for _, line := range strings.Split(comment, "\n") {
+		out.WriteString("# " + line + "\n")
+	}
+}
+
+// Write the keys of a table, prefix comments every line out
+func writeTable(out *strings.Builder, prefix string, path string, table reflect.Value) error {
+	// Sub-tables have to come after the keys, or the keys would belong to them
+	var subTables []reflect.StructField
+
+	for i := 0; i < table.NumField(); i++ {
+		field := table.Type().Field(i)
+		name := tomlName(field)
+		if name == "" {
+			continue
+		}
+
+		value := table.Field(i)
+		key := path + "." + name
+
+		if (value.Kind() == reflect.Struct && !isTextUnmarshaler(value)) || isOptionalTable(field.Type) {
+			subTables = append(subTables, field)
+			continue
+		}
+
+		writeComment(out, settingDocs[key])
+
+		// Unset optional settings, and the keys of optional tables, are written commented out with an example value
+		if (value.Kind() == reflect.Pointer && value.IsNil()) || (prefix != "" && value.IsZero() && field.Tag.Get("example") != "") {
+			out.WriteString("# " + name + " = " + strconv.Quote(field.Tag.Get("example")) + "\n")
+			continue
+		}
+
+		rendered, err := renderValue(value)
+		if err != nil {
+			return fmt.Errorf("failed rendering the default of %s -> %w", key, err)
+		}
+		out.WriteString(prefix + name + " = " + rendered + "\n")
+	}
+
+	for _, field := range subTables {
+		key := path + "." + tomlName(field)
+
+		// Sub-tables of arrays of tables, like [tenants.credentials], just point at their top level counterpart
+		if field.Type.Kind() == reflect.Struct {
+			out.WriteString(prefix + "[" + key + "]\n")
+			writeComment(out, settingDocs[key])
+			continue
+		}
+
+		// Optional tables are written commented out, with their keys' zero values
+		writeComment(out, settingDocs[key])
+		out.WriteString("# [" + key + "]\n")
+		if err := writeTable(out, "# ", key, reflect.New(field.Type.Elem()).Elem()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Render a value the way settings_template.toml writes it
+func renderValue(value reflect.Value) (string, error) {
+	if value.Kind() == reflect.Pointer {
+		return renderValue(value.Elem())
+	}
+
+	// "15m" rather than "15m0s"
+	if duration, ok := value.Interface().(Duration); ok {
+		text := duration.String()
+		if strings.HasSuffix(text, "m0s") {
+			text = strings.TrimSuffix(text, "0s")
+		}
+		if strings.HasSuffix(text, "h0m") {
+			text = strings.TrimSuffix(text, "0m")
+		}
+		return strconv.Quote(text), nil
+	}
+
+	// Before MarshalText, which masks secrets
+	if value.Kind() == reflect.String {
+		return strconv.Quote(value.String()), nil
+	}
+
+	if marshaler, ok := value.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		if err != nil {
+			return "", err
+		}
+		return strconv.Quote(string(text)), nil
+	}
+
+	switch value.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprint(value.Interface()), nil
+	case reflect.Float32, reflect.Float64:
+		text := strconv.FormatFloat(value.Float(), 'f', -1, 64)
+		if !strings.ContainsAny(text, ".eE") {
+			text += ".0" // A bare 1 would be a TOML integer
+		}
+		return text, nil
+	case reflect.Slice:
+		var items strings.Builder
+		items.WriteString("[\n")
+		for i := 0; i < value.Len(); i++ {
+			item, err := renderValue(value.Index(i))
+			if err != nil {
+				return "", err
+			}
+			items.WriteString("    " + item + ",\n")
+		}
+		items.WriteString("]")
+		return items.String(), nil
+	default:
+		return "", fmt.Errorf("unsupported setting type %s", value.Type())
+	}
+}
@@ -0,0 +1,199 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package appsettings
+
+import (
+	"azure_app_exporter/logging"
+	"encoding"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Every setting can be overridden by an env var named after its toml path, e.g. [credentials] client_secret
+// is AZURE_APP_EXPORTER_CREDENTIALS__CLIENT_SECRET and the first [[tenants]] entry's tenant_id is
+// AZURE_APP_EXPORTER_TENANTS__0__CREDENTIALS__TENANT_ID. Lists are comma separated.
+const envPrefix = "AZURE_APP_EXPORTER_"
+
+// Return the env var overriding the setting at the given toml path
+func envName(path ...string) string {
+	return envPrefix + strings.ToUpper(strings.Join(path, "__"))
+}
+
+// Whether any env var starts with the given prefix, used to find [[tenants]] entries only provided through env vars
+func hasEnvPrefix(prefix string) bool {
+	for _, env := range os.Environ() {
+		if strings.HasPrefix(env, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Apply the env var overrides on top of the settings read from the file, returning how many were applied
+func applyEnvOverrides(settings *Settings) (int, error) {
+	var (
+		applied int
+		errs    []error
+		known   = map[string]bool{envPrefix + "SETTINGS_PATH": true}
+	)
+
+	var walk func(value reflect.Value, path []string)
+	walk = func(value reflect.Value, path []string) {
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+
+			tag, _, _ := strings.Cut(field.Tag.Get("toml"), ",")
+			if tag == "" || tag == "-" {
+				continue
+			}
+
+			fieldPath := append(path[:len(path):len(path)], tag)
+			fieldValue := value.Field(i)
+
+			if field.Type.Kind() == reflect.Struct && !isTextUnmarshaler(fieldValue) {
+				walk(fieldValue, fieldPath)
+				continue
+			}
+
+			// Optional tables like [credentials.client_secret_provider] are created by the first env var setting one of their keys
+			if isOptionalTable(field.Type) {
+				if fieldValue.IsNil() {
+					if !hasEnvPrefix(envName(fieldPath...) + "__") {
+						continue
+					}
+					fieldValue.Set(reflect.New(field.Type.Elem()))
+				}
+				walk(fieldValue.Elem(), fieldPath)
+				continue
+			}
+
+			// Arrays of tables like [[tenants]] are indexed, and may be extended by env vars
+			if field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct {
+				for index := 0; index < fieldValue.Len() || hasEnvPrefix(envName(append(fieldPath, strconv.Itoa(index))...)+"__"); index++ {
+					if index == fieldValue.Len() {
+						fieldValue.Set(reflect.Append(fieldValue, reflect.Zero(field.Type.Elem())))
+					}
+					walk(fieldValue.Index(index), append(fieldPath, strconv.Itoa(index)))
+				}
+				continue
+			}
+
+			name := envName(fieldPath...)
+			known[name] = true
+
+			raw, ok := os.LookupEnv(name)
+			if !ok {
+				continue
+			}
+
+			if err := setFromText(fieldValue, raw); err != nil {
+				errs = append(errs, fmt.Errorf("invalid value in env var %s -> %w", name, err))
+				continue
+			}
+			applied++
+		}
+	}
+
+	walk(reflect.ValueOf(settings).Elem(), nil)
+
+	// Most likely a typo, which would otherwise be silently ignored
+	for _, env := range os.Environ() {
+		if name, _, _ := strings.Cut(env, "="); strings.HasPrefix(name, envPrefix) && !known[name] {
+			logging.With("component", "settings").Warnf("env var %s does not match any setting, ignoring it", name)
+		}
+	}
+
+	return applied, errors.Join(errs...)
+}
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+// A pointer to a table, as opposed to a pointer to a value like *Duration
+func isOptionalTable(fieldType reflect.Type) bool {
+	return fieldType.Kind() == reflect.Pointer && fieldType.Elem().Kind() == reflect.Struct && !reflect.PointerTo(fieldType.Elem()).Implements(textUnmarshalerType)
+}
+
+func isTextUnmarshaler(value reflect.Value) bool {
+	_, ok := value.Addr().Interface().(encoding.TextUnmarshaler)
+	return ok
+}
+
+// Parse raw into value the same way the toml decoder does, through UnmarshalText where it is implemented
+func setFromText(value reflect.Value, raw string) error {
+	if value.Kind() == reflect.Pointer {
+		target := reflect.New(value.Type().Elem())
+		if err := setFromText(target.Elem(), raw); err != nil {
+			return err
+		}
+		value.Set(target)
+		return nil
+	}
+
+	if unmarshaler, ok := value.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(raw))
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		value.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(raw, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(raw, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(raw, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(parsed)
+	case reflect.Slice:
+		items := strings.Split(raw, ",")
+		slice := reflect.MakeSlice(value.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setFromText(slice.Index(i), strings.TrimSpace(item)); err != nil {
+				return err
+			}
+		}
+		value.Set(slice)
+	default:
+		return fmt.Errorf("unsupported setting type %s", value.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package appsettings
+
+import (
+	"crypto/tls"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestApplyEnvOverrides(t *testing.T) {
+	cases := []struct {
+		name        string
+		env         map[string]string
+		initial     func(*Settings)
+		wantApplied int
+		wantErr     bool
+		check       func(*testing.T, Settings)
+	}{
+		{
+			name:        "string",
+			env:         map[string]string{"AZURE_APP_EXPORTER_CREDENTIALS__CLIENT_ID": "from-env"},
+			wantApplied: 1,
+			check: func(t *testing.T, s Settings) {
+				if s.Credentials.ClientId != "from-env" {
+					t.Errorf("client_id = %q", s.Credentials.ClientId)
+				}
+			},
+		},
+		{
+			name: "bool, int, float and duration",
+			env: map[string]string{
+				"AZURE_APP_EXPORTER_DEBUG__NO_VERIFY_TLS":                 "true",
+				"AZURE_APP_EXPORTER_RETRY__MAX_ATTEMPTS":                  "7",
+				"AZURE_APP_EXPORTER_TRACING__SAMPLE_RATIO":                "0.25",
+				"AZURE_APP_EXPORTER_APPLICATIONS__CACHE_REFRESH_INTERVAL": "30m",
+			},
+			wantApplied: 4,
+			check: func(t *testing.T, s Settings) {
+				if !s.Debug.NoVerifyTls {
+					t.Error("no_verify_tls not set")
+				}
+				if s.Retry.MaxAttempts != 7 {
+					t.Errorf("max_attempts = %d", s.Retry.MaxAttempts)
+				}
+				if s.Tracing.SampleRatio != 0.25 {
+					t.Errorf("sample_ratio = %g", s.Tracing.SampleRatio)
+				}
+				if s.Applications.CacheRefreshInterval.Duration != 30*time.Minute {
+					t.Errorf("cache_refresh_interval = %s", s.Applications.CacheRefreshInterval)
+				}
+			},
+		},
+		{
+			name:        "comma separated list",
+			env:         map[string]string{"AZURE_APP_EXPORTER_TLS__PROTOCOL_VERSIONS": "TLS12, TLS13"},
+			wantApplied: 1,
+			check: func(t *testing.T, s Settings) {
+				want := []ProtocolVersion{ProtocolVersion(tls.VersionTLS12), ProtocolVersion(tls.VersionTLS13)}
+				if !slices.Equal(s.Tls.ProtocolVersions, want) {
+					t.Errorf("protocol_versions = %v", s.Tls.ProtocolVersions)
+				}
+			},
+		},
+		{
+			name: "extends tenants",
+			env: map[string]string{
+				"AZURE_APP_EXPORTER_TENANTS__0__CREDENTIALS__CLIENT_ID": "first",
+				"AZURE_APP_EXPORTER_TENANTS__1__CREDENTIALS__CLIENT_ID": "second",
+			},
+			initial: func(s *Settings) {
+				s.Tenants = []Tenant{{Credentials: Credentials{TenantId: "from-file"}}}
+			},
+			wantApplied: 2,
+			check: func(t *testing.T, s Settings) {
+				if len(s.Tenants) != 2 {
+					t.Fatalf("got %d tenants", len(s.Tenants))
+				}
+				if s.Tenants[0].Credentials.TenantId != "from-file" || s.Tenants[0].Credentials.ClientId != "first" {
+					t.Errorf("tenants[0] = %+v", s.Tenants[0].Credentials)
+				}
+				if s.Tenants[1].Credentials.ClientId != "second" {
+					t.Errorf("tenants[1] = %+v", s.Tenants[1].Credentials)
+				}
+			},
+		},
+		{
+			name:        "creates optional table",
+			env:         map[string]string{"AZURE_APP_EXPORTER_CREDENTIALS__CLIENT_SECRET_PROVIDER__TYPE": "azure_key_vault"},
+			wantApplied: 1,
+			check: func(t *testing.T, s Settings) {
+				if s.Credentials.ClientSecretProvider == nil || s.Credentials.ClientSecretProvider.Type != SecretProviderAzureKeyVault {
+					t.Errorf("client_secret_provider = %+v", s.Credentials.ClientSecretProvider)
+				}
+			},
+		},
+		{
+			name:        "leaves absent optional table nil",
+			env:         map[string]string{},
+			wantApplied: 0,
+			check: func(t *testing.T, s Settings) {
+				if s.Credentials.ClientSecretProvider != nil {
+					t.Errorf("client_secret_provider = %+v", s.Credentials.ClientSecretProvider)
+				}
+			},
+		},
+		{
+			name:    "invalid value",
+			env:     map[string]string{"AZURE_APP_EXPORTER_RETRY__MAX_ATTEMPTS": "many"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid enum",
+			env:     map[string]string{"AZURE_APP_EXPORTER_TRACING__PROTOCOL": "udp"},
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for name, value := range c.env {
+				t.Setenv(name, value)
+			}
+
+			settings := Defaults()
+			if c.initial != nil {
+				c.initial(&settings)
+			}
+
+			applied, err := applyEnvOverrides(&settings)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("err = %v, want error %t", err, c.wantErr)
+			}
+			if c.wantErr {
+				return
+			}
+			if applied != c.wantApplied {
+				t.Errorf("applied = %d, want %d", applied, c.wantApplied)
+			}
+			c.check(t, settings)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package appsettings
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// How log records are written
+type LogFormat string
+
+const (
+	// logfmt key=value pairs
+	LogFormatText LogFormat = "text"
+	// One JSON object per line
+	LogFormatJson LogFormat = "json"
+)
+
+var logFormatValue = map[string]LogFormat{
+	string(LogFormatText): LogFormatText,
+	string(LogFormatJson): LogFormatJson,
+}
+
+func (l *LogFormat) UnmarshalText(bytes []byte) error {
+	name := string(bytes)
+
+	if logFormat, ok := logFormatValue[name]; ok {
+		*l = logFormat
+		return nil
+	}
+
+	return fmt.Errorf("invalid log format %s, expected one of %v", name, reflect.ValueOf(logFormatValue).MapKeys())
+}
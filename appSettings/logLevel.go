@@ -0,0 +1,60 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package appsettings
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Minimum level of the logged records
+type LogLevel string
+
+const (
+	// Every request to Azure and every cache update
+	LogLevelDebug LogLevel = "debug"
+	// Successful updates, startup and shutdown
+	LogLevelInfo LogLevel = "info"
+	// Recoverable problems, like a throttled request
+	LogLevelWarn LogLevel = "warn"
+	// Failed updates
+	LogLevelError LogLevel = "error"
+	// Nothing at all
+	LogLevelOff LogLevel = "off"
+)
+
+var logLevelValue = map[string]LogLevel{
+	string(LogLevelDebug): LogLevelDebug,
+	string(LogLevelInfo):  LogLevelInfo,
+	string(LogLevelWarn):  LogLevelWarn,
+	string(LogLevelError): LogLevelError,
+	string(LogLevelOff):   LogLevelOff,
+}
+
+func (l *LogLevel) UnmarshalText(bytes []byte) error {
+	name := string(bytes)
+
+	if logLevel, ok := logLevelValue[name]; ok {
+		*l = logLevel
+		return nil
+	}
+
+	return fmt.Errorf("invalid log level %s, expected one of %v", name, reflect.ValueOf(logLevelValue).MapKeys())
+}
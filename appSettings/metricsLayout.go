@@ -0,0 +1,51 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package appsettings
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Which labels the Azure credential metrics carry
+type MetricsLayout string
+
+const (
+	// Every value series carries all the descriptive labels, including display names and the end date
+	MetricsLayoutLegacy MetricsLayout = "legacy"
+	// Value series only carry identifying labels, the descriptive ones are moved to *_info series
+	MetricsLayoutCompact MetricsLayout = "compact"
+)
+
+var metricsLayoutValue = map[string]MetricsLayout{
+	string(MetricsLayoutLegacy):  MetricsLayoutLegacy,
+	string(MetricsLayoutCompact): MetricsLayoutCompact,
+}
+
+func (m *MetricsLayout) UnmarshalText(bytes []byte) error {
+	name := string(bytes)
+
+	if metricsLayout, ok := metricsLayoutValue[name]; ok {
+		*m = metricsLayout
+		return nil
+	}
+
+	return fmt.Errorf("invalid metrics layout %s, expected one of %v", name, reflect.ValueOf(metricsLayoutValue).MapKeys())
+}
@@ -0,0 +1,162 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package appsettings
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Where [credentials.client_secret_provider] fetches the client secret from
+type SecretProviderType string
+
+const (
+	// https://learn.microsoft.com/en-us/rest/api/keyvault/secrets/get-secret/get-secret
+	SecretProviderAzureKeyVault SecretProviderType = "azure_key_vault"
+	// https://developer.hashicorp.com/vault/api-docs/secret/kv
+	SecretProviderHashicorpVault SecretProviderType = "hashicorp_vault"
+)
+
+var secretProviderTypeValue = map[string]SecretProviderType{
+	string(SecretProviderAzureKeyVault):  SecretProviderAzureKeyVault,
+	string(SecretProviderHashicorpVault): SecretProviderHashicorpVault,
+}
+
+func (s *SecretProviderType) UnmarshalText(bytes []byte) error {
+	name := string(bytes)
+
+	if secretProviderType, ok := secretProviderTypeValue[name]; ok {
+		*s = secretProviderType
+		return nil
+	}
+
+	return fmt.Errorf("invalid secret provider type %s, expected one of %v", name, reflect.ValueOf(secretProviderTypeValue).MapKeys())
+}
+
+// An external secret store the client secret is fetched from on every token refresh
+type SecretProvider struct {
+	Type SecretProviderType `toml:"type" json:"type" extensions:"x-order=1" swaggertype:"string" enums:"azure_key_vault,hashicorp_vault" example:"azure_key_vault"`
+
+	// Azure Key Vault, authenticated with the managed or workload identity of the host running the exporter
+	VaultUrl         string          `toml:"vault_url"          json:"vault_url"          extensions:"x-order=2" example:"https://my-vault.vault.azure.net"`
+	SecretName       string          `toml:"secret_name"        json:"secret_name"        extensions:"x-order=3" example:"azure-app-exporter"`
+	SecretVersion    *string         `toml:"secret_version"     json:"secret_version"     extensions:"x-order=4,x-nullable" example:"0123456789abcdef0123456789abcdef"`
+	Identity         CredentialsMode `toml:"identity"           json:"identity"           extensions:"x-order=5" swaggertype:"string" enums:"managed_identity,workload_identity" example:"managed_identity"`
+	IdentityClientId *string         `toml:"identity_client_id" json:"identity_client_id" extensions:"x-order=6,x-nullable" example:"00000000-0000-0000-0000-000000000000"`
+
+	// HashiCorp Vault, reading a KV v1 or v2 secret
+	Address   *string `toml:"address"    json:"address"    extensions:"x-order=7,x-nullable" example:"https://vault.example.com:8200"`
+	Path      string  `toml:"path"       json:"path"       extensions:"x-order=8" example:"secret/data/azure_app_exporter"`
+	Key       string  `toml:"key"        json:"key"        extensions:"x-order=9" example:"client_secret"`
+	TokenFile *string `toml:"token_file" json:"token_file" extensions:"x-order=10,x-nullable" example:"/vault/secrets/token"`
+	Namespace *string `toml:"namespace"  json:"namespace"  extensions:"x-order=11,x-nullable" example:"admin"`
+}
+
+// The Key Vault resource tokens are requested for, e.g. https://vault.azure.net for https://my-vault.vault.azure.net
+func (s SecretProvider) KeyVaultResource() string {
+	vaultUrl, err := url.Parse(s.VaultUrl)
+	if err != nil {
+		return ""
+	}
+
+	_, domain, _ := strings.Cut(vaultUrl.Hostname(), ".")
+	return vaultUrl.Scheme + "://" + domain
+}
+
+// The Vault server without a trailing slash, falling back to the VAULT_ADDR env var
+func (s SecretProvider) ResolvedAddress() string {
+	if s.Address != nil {
+		return strings.TrimRight(*s.Address, "/")
+	}
+
+	return strings.TrimRight(os.Getenv("VAULT_ADDR"), "/")
+}
+
+// The credentials of the identity which reads the Key Vault secret. It shares the endpoints and cloud of the tenant,
+// and workload identity falls back to the env vars injected by its webhook for the tenant and client ID.
+func (c Credentials) KeyVaultIdentity() Credentials {
+	provider := *c.ClientSecretProvider
+
+	identity := Credentials{
+		Mode:                    provider.Identity,
+		ManagedIdentityEndpoint: c.ManagedIdentityEndpoint,
+		FederatedTokenFile:      c.FederatedTokenFile,
+		Cloud:                   c.Cloud,
+		AuthorityHost:           c.AuthorityHost,
+		GraphEndpoint:           c.GraphEndpoint,
+	}
+	if provider.IdentityClientId != nil {
+		identity.ClientId = *provider.IdentityClientId
+	}
+
+	return identity
+}
+
+// Fill in the defaults of a [credentials.client_secret_provider] table, which is decoded into zero values
+func (s *SecretProvider) setDefaults() {
+	if s.Identity == "" {
+		s.Identity = CredentialsModeManagedIdentity
+	}
+	if s.Key == "" {
+		s.Key = "client_secret"
+	}
+}
+
+func isAbsoluteUrl(rawUrl string) bool {
+	parsed, err := url.Parse(rawUrl)
+	return err == nil && parsed.Scheme != "" && parsed.Host != ""
+}
+
+func validateSecretProvider(prefix string, s SecretProvider) error {
+	var errs []error
+
+	switch s.Type {
+	case SecretProviderAzureKeyVault:
+		if !isAbsoluteUrl(s.VaultUrl) {
+			errs = append(errs, fmt.Errorf("%svault_url %q must be an absolute url", prefix, s.VaultUrl))
+		}
+		if s.SecretName == "" {
+			errs = append(errs, fmt.Errorf("%ssecret_name is required with secret provider %s", prefix, s.Type))
+		}
+		if s.Identity != CredentialsModeManagedIdentity && s.Identity != CredentialsModeWorkloadIdentity {
+			errs = append(errs, fmt.Errorf("%sidentity must be %s or %s", prefix, CredentialsModeManagedIdentity, CredentialsModeWorkloadIdentity))
+		}
+	case SecretProviderHashicorpVault:
+		if address := s.ResolvedAddress(); address == "" {
+			errs = append(errs, fmt.Errorf("%saddress or VAULT_ADDR is required with secret provider %s", prefix, s.Type))
+		} else if !isAbsoluteUrl(address) {
+			errs = append(errs, fmt.Errorf("%saddress %q must be an absolute url", prefix, address))
+		}
+		if s.Path == "" {
+			errs = append(errs, fmt.Errorf("%spath is required with secret provider %s", prefix, s.Type))
+		}
+		if s.TokenFile == nil && os.Getenv("VAULT_TOKEN") == "" {
+			errs = append(errs, fmt.Errorf("%stoken_file or VAULT_TOKEN is required with secret provider %s", prefix, s.Type))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("%stype is required", prefix))
+	}
+
+	return errors.Join(errs...)
+}
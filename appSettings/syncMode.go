@@ -0,0 +1,51 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package appsettings
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// How the cached applications are refreshed
+type SyncMode string
+
+const (
+	// List every application on each refresh
+	SyncModeFull SyncMode = "full"
+	// Crawl the delta query once, then only fetch the changes since the previous refresh
+	SyncModeDelta SyncMode = "delta"
+)
+
+var syncModeValue = map[string]SyncMode{
+	string(SyncModeFull):  SyncModeFull,
+	string(SyncModeDelta): SyncModeDelta,
+}
+
+func (s *SyncMode) UnmarshalText(bytes []byte) error {
+	name := string(bytes)
+
+	if syncMode, ok := syncModeValue[name]; ok {
+		*s = syncMode
+		return nil
+	}
+
+	return fmt.Errorf("invalid sync mode %s, expected one of %v", name, reflect.ValueOf(syncModeValue).MapKeys())
+}
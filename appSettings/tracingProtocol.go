@@ -0,0 +1,51 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package appsettings
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Transport of the OTLP trace exporter
+type TracingProtocol string
+
+const (
+	// OTLP/HTTP with protobuf payloads, port 4318 by default
+	TracingProtocolHttp TracingProtocol = "http"
+	// OTLP/gRPC, port 4317 by default
+	TracingProtocolGrpc TracingProtocol = "grpc"
+)
+
+var tracingProtocolValue = map[string]TracingProtocol{
+	string(TracingProtocolHttp): TracingProtocolHttp,
+	string(TracingProtocolGrpc): TracingProtocolGrpc,
+}
+
+func (t *TracingProtocol) UnmarshalText(bytes []byte) error {
+	name := string(bytes)
+
+	if tracingProtocol, ok := tracingProtocolValue[name]; ok {
+		*t = tracingProtocol
+		return nil
+	}
+
+	return fmt.Errorf("invalid tracing protocol %s, expected one of %v", name, reflect.ValueOf(tracingProtocolValue).MapKeys())
+}
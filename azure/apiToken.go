@@ -24,10 +24,17 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"reflect"
 	"time"
 
 	appmetrics "azure_app_exporter/appMetrics"
+	appsettings "azure_app_exporter/appSettings"
 	globalstate "azure_app_exporter/globalState"
+	"azure_app_exporter/tracing"
+
+	"github.com/carlmjohnson/requests"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type authToken struct {
@@ -36,34 +43,100 @@ type authToken struct {
 }
 
 // https://learn.microsoft.com/en-us/graph/auth-v2-service#4-request-an-access-token
-func AzureApiTokenUpdater() {
+func clientCredentialsToken(ctx context.Context, httpClient *requests.Builder, credentials appsettings.Credentials) (authToken, error) {
+	requestUrl := fmt.Sprintf("%s/%s/oauth2/v2.0/token", credentials.ResolvedAuthorityHost(), credentials.TenantId)
+
+	form := url.Values{
+		"grant_type": {"client_credentials"},
+		"scope":      {credentials.GraphScope()},
+		"client_id":  {credentials.ClientId},
+	}
+
+	// https://learn.microsoft.com/en-us/graph/auth-v2-service#token-request
+	if credentials.UsesCertificate() {
+		logging.With("component", "token", "url", requestUrl).Debug("calling with client id and certificate")
+
+		assertion, err := newClientAssertion(credentials, requestUrl)
+		if err != nil {
+			return authToken{}, fmt.Errorf("failed creating client assertion -> %w", err)
+		}
+
+		form.Set("client_assertion_type", clientAssertionType)
+		form.Set("client_assertion", assertion)
+	} else {
+		logging.With("component", "token", "url", requestUrl).Debug("calling with client id and secret")
+
+		clientSecret, err := resolveClientSecret(ctx, httpClient, credentials)
+		if err != nil {
+			return authToken{}, fmt.Errorf("failed resolving client secret -> %w", err)
+		}
+
+		form.Set("client_secret", clientSecret)
+	}
+
+	var response authToken
+	err := httpClient.
+		BaseURL(requestUrl).
+		Post().
+		BodyForm(form).
+		ToJSON(&response).
+		Fetch(ctx)
+
+	return response, err
+}
+
+// Keep the tenant's api token fresh until ctx is cancelled
+func AzureApiTokenUpdater(ctx context.Context, tenant *globalstate.Tenant) {
+	log := tenant.Logger("token")
 	httpClient := globalstate.HttpClient.Clone()
 
-	inner := func() (time.Duration, error) {
-		requestUrl := fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", globalstate.Settings.Credentials.TenantId)
-		logging.Debugf("calling with client id and secret: %s", requestUrl)
-
-		var response authToken
-		if err := httpClient.
-			BaseURL(requestUrl).
-			Post().
-			BodyForm(url.Values{
-				"grant_type":    {"client_credentials"},
-				"scope":         {"https://graph.microsoft.com/.default"},
-				"client_id":     {globalstate.Settings.Credentials.ClientId},
-				"client_secret": {string(globalstate.Settings.Credentials.ClientSecret)},
-			}).
-			ToJSON(&response).
-			Fetch(context.Background()); err != nil {
+	// Credentials the current token was requested with, a reload that changes them triggers an immediate refresh
+	var usedCredentials appsettings.Credentials
+
+	inner := func(ctx context.Context) (time.Duration, error) {
+		var (
+			response authToken
+			err      error
+		)
+
+		credentials := tenant.Settings().Credentials
+		usedCredentials = credentials
+
+		switch credentials.Mode {
+		case appsettings.CredentialsModeManagedIdentity:
+			response, err = managedIdentityToken(ctx, httpClient, credentials, credentials.ResolvedGraphEndpoint())
+		case appsettings.CredentialsModeWorkloadIdentity:
+			response, err = workloadIdentityToken(ctx, httpClient, credentials, credentials.ResolvedGraphEndpoint())
+		default:
+			response, err = clientCredentialsToken(ctx, httpClient, credentials)
+		}
+		if err != nil {
 			return 0, err
 		}
 
-		globalstate.AzureApiToken.RwLock.Lock()
-		defer globalstate.AzureApiToken.RwLock.Unlock()
+		tenant.AzureApiToken.RwLock.Lock()
+		defer tenant.AzureApiToken.RwLock.Unlock()
+
+		tenant.AzureApiToken.Value = response.AccessToken
 
-		globalstate.AzureApiToken.Value = response.AccessToken
+		validity := time.Duration(response.ExpiresIn) * time.Second
+		tenant.AzureApiToken.ExpiresAt = time.Now().Add(validity)
+		appmetrics.TokenExpiry.WithLabelValues(tenant.Id).Set(float64(tenant.AzureApiToken.ExpiresAt.Unix()))
 
-		return time.Duration(response.ExpiresIn) * time.Second, nil
+		return validity, nil
+	}
+
+	refresh := func() (time.Duration, error) {
+		ctx, span := tracing.Tracer().Start(ctx, "refresh azure api token", trace.WithAttributes(
+			tracing.TenantKey.String(tenant.Id),
+			attribute.String("credentials.mode", string(tenant.Settings().Credentials.Mode)),
+		))
+		defer span.End()
+
+		validity, err := inner(ctx)
+		tracing.RecordError(span, err)
+
+		return validity, err
 	}
 
 	for {
@@ -71,16 +144,28 @@ func AzureApiTokenUpdater() {
 
 		sleepDuration := 30 * time.Second
 
-		if duration, err := inner(); err == nil {
+		if duration, err := refresh(); err == nil {
 			elapsed := time.Since(start)
 			sleepDuration = time.Duration(duration.Seconds()*0.9) * time.Second // Sleep for 90% of the token's validity duration
-			logging.Infof("updated azure api token in %s, next update after %s", elapsed, sleepDuration)
-			appmetrics.TokenSeconds.Observe(elapsed.Seconds())
+			log.With("latency", elapsed, "next_update", sleepDuration).Info("updated azure api token")
+			appmetrics.TokenSeconds.WithLabelValues(tenant.Id).Observe(elapsed.Seconds())
+			appmetrics.TokenLastSuccess.WithLabelValues(tenant.Id).SetToCurrentTime()
+		} else if ctx.Err() != nil {
+			return
 		} else {
-			logging.Errorf("failed updating api token -> %s, new attempt after %s", err, sleepDuration)
-			appmetrics.TokenFailures.Inc()
+			log.WithError(err).With("next_update", sleepDuration).Error("failed updating azure api token")
+			appmetrics.TokenFailures.WithLabelValues(tenant.Id).Inc()
 		}
 
-		time.Sleep(sleepDuration)
+		deadline := func() time.Time {
+			if !reflect.DeepEqual(usedCredentials, tenant.Settings().Credentials) {
+				return time.Now()
+			}
+			return start.Add(sleepDuration)
+		}
+
+		if !globalstate.SleepUntil(ctx, deadline) {
+			return
+		}
 	}
 }
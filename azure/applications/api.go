@@ -21,6 +21,7 @@ package applications
 
 import (
 	"net/http"
+	"time"
 
 	datatypes "azure_app_exporter/azure/applications/dataTypes"
 	fromswaggerui "azure_app_exporter/fromSwaggerUi"
@@ -33,45 +34,79 @@ import (
 // @description Show all Azure applications cached in the exporter (truncated in Swagger UI to 50 entries)
 // @description
 // @description Call this endpoint outside Swagger UI to see full response
+// @description
+// @description Every filter that is set has to match. Credentials are both the passwords and the certificates of an application.
 // @tags applications
+// @param tenant_id query string false "Only show applications of this tenant"
+// @param display_name query string false "Case insensitive substring of the display name, or a regular expression enclosed in slashes like /^prod-/"
+// @param app_id query string false "Only show the application with this client ID"
+// @param expiring_within query string false "Only show applications with a credential expiring within this duration, like 30d or 12h"
+// @param expired query bool false "Only show applications with (true) or without (false) an expired credential"
+// @param has_credentials query bool false "Only show applications with (true) or without (false) any credentials"
+// @param no_end_date query bool false "Only show applications with (true) or without (false) a credential that never expires"
 // @produce json
 // @success 200 {object} map[string]datatypes.AzureApplication
+// @failure 400 "Invalid filter"
+// @failure 404 "Unknown tenant"
 // @router /api/apps [get]
 func AllApplications(c echo.Context) error {
-	globalstate.Applications.RwLock.RLock()
-	defer globalstate.Applications.RwLock.RUnlock()
+	tenants, ok := globalstate.SelectTenants(c.QueryParam("tenant_id"))
+	if !ok {
+		return c.NoContent(http.StatusNotFound)
+	}
+
+	filter, err := parseApplicationFilter(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
 
+	limit := -1
 	if _, fromUi := c.Request().Header[fromswaggerui.HeaderName]; fromUi {
-		i := 0
-		truncated := make(map[string]datatypes.AzureApplication, 50)
+		limit = 50
+	}
+
+	applications := make(map[string]datatypes.AzureApplication)
+	now := time.Now()
 
-		for id, application := range globalstate.Applications.Value {
-			if i >= 50 {
+	for _, tenant := range tenants {
+		tenant.Applications.RwLock.RLock()
+		for id, application := range tenant.Applications.Value {
+			if limit >= 0 && len(applications) >= limit {
 				break
 			}
-			truncated[id] = application
-			i++
+			if filter.matches(application, now) {
+				applications[id] = application
+			}
 		}
-
-		return c.JSON(http.StatusOK, truncated)
+		tenant.Applications.RwLock.RUnlock()
 	}
 
-	return c.JSON(http.StatusOK, globalstate.Applications.Value)
+	return c.JSON(http.StatusOK, applications)
 }
 
 // @summary Show Azure application by ID
 // @description Show Azure application by ID
 // @tags applications
 // @param id path string true "ID of Azure application to lookup"
+// @param tenant_id query string false "Only lookup the application in this tenant"
 // @produce json
 // @success 200 {object} datatypes.AzureApplication
+// @failure 404 "Unknown application or tenant"
 // @router /api/apps/{id} [get]
 func ApplicationById(c echo.Context) error {
-	globalstate.Applications.RwLock.RLock()
-	defer globalstate.Applications.RwLock.RUnlock()
+	tenants, ok := globalstate.SelectTenants(c.QueryParam("tenant_id"))
+	if !ok {
+		return c.NoContent(http.StatusNotFound)
+	}
 
-	if application, ok := globalstate.Applications.Value[c.Param("id")]; ok {
-		return c.JSON(http.StatusOK, application)
+	for _, tenant := range tenants {
+		tenant.Applications.RwLock.RLock()
+		application, ok := tenant.Applications.Value[c.Param("id")]
+		tenant.Applications.RwLock.RUnlock()
+
+		if ok {
+			return c.JSON(http.StatusOK, application)
+		}
 	}
 
 	return c.NoContent(http.StatusNotFound)
@@ -0,0 +1,169 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package applications
+
+import (
+	"azure_app_exporter/logging"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	appmetrics "azure_app_exporter/appMetrics"
+	appsettings "azure_app_exporter/appSettings"
+	datatypes "azure_app_exporter/azure/applications/dataTypes"
+	globalstate "azure_app_exporter/globalState"
+)
+
+// Bump whenever the layout of cacheFile or of the cached applications changes incompatibly
+const cacheFileVersion = 1
+
+type cacheFile struct {
+	Version int                        `json:"version"`
+	Tenants map[string]cacheFileTenant `json:"tenants"`
+}
+
+type cacheFileTenant struct {
+	UpdatedAt    time.Time                             `json:"updated_at"`
+	FullSyncAt   time.Time                             `json:"full_sync_at"`
+	DeltaLink    string                                `json:"delta_link,omitempty"`
+	Applications map[string]datatypes.AzureApplication `json:"applications"`
+}
+
+// Serializes the writes of the updaters of different tenants
+var cacheFileLock sync.Mutex
+
+// Atomically replace [applications] cache_file with the current snapshots of every tenant
+func saveCacheFile() error {
+	path := globalstate.Settings().Applications.CacheFile
+	if path == nil {
+		return nil
+	}
+
+	cacheFileLock.Lock()
+	defer cacheFileLock.Unlock()
+
+	contents := cacheFile{Version: cacheFileVersion, Tenants: make(map[string]cacheFileTenant, len(globalstate.Tenants))}
+
+	for _, tenant := range globalstate.Tenants {
+		tenant.Applications.RwLock.RLock()
+		if !tenant.Applications.UpdatedAt.IsZero() {
+			contents.Tenants[tenant.Id] = cacheFileTenant{
+				UpdatedAt:    tenant.Applications.UpdatedAt,
+				FullSyncAt:   tenant.Applications.FullSyncAt,
+				DeltaLink:    tenant.Applications.DeltaLink,
+				Applications: tenant.Applications.Value,
+			}
+		}
+		tenant.Applications.RwLock.RUnlock()
+	}
+
+	// Write to a temporary file in the same directory and rename it, so readers never see a partial file
+	file, err := os.CreateTemp(filepath.Dir(*path), filepath.Base(*path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if err := json.NewEncoder(file).Encode(contents); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), *path)
+}
+
+// Seed the cache of every tenant from [applications] cache_file, keeping the age of the snapshot.
+// A missing, unreadable or outdated file is not fatal, the updaters will crawl Graph as usual.
+func LoadCacheFile() {
+	path := globalstate.Settings().Applications.CacheFile
+	if path == nil {
+		return
+	}
+
+	contents, err := readCacheFile(*path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			logging.With("component", "applications", "path", *path).Info("applications cache file does not exist yet")
+		} else {
+			logging.With("component", "applications", "path", *path).WithError(err).Warn("ignoring applications cache file")
+		}
+		return
+	}
+
+	for _, tenant := range globalstate.Tenants {
+		cached, ok := contents.Tenants[tenant.Id]
+		if !ok || cached.Applications == nil {
+			continue
+		}
+
+		tenant.Applications.RwLock.Lock()
+		tenant.Applications.Value = cached.Applications
+		tenant.Applications.UpdatedAt = cached.UpdatedAt
+		tenant.Applications.FullSyncAt = cached.FullSyncAt
+		if globalstate.Settings().Applications.SyncMode == appsettings.SyncModeDelta {
+			tenant.Applications.DeltaLink = cached.DeltaLink
+		}
+		tenant.Applications.RwLock.Unlock()
+
+		updateCacheMetrics(tenant.Id, cached.Applications)
+		appmetrics.ApplicationsLastSuccess.WithLabelValues(tenant.Id).Set(float64(cached.UpdatedAt.UnixMilli()) / 1000)
+
+		log := tenant.Logger("applications").With("path", *path)
+		age := time.Since(cached.UpdatedAt).Truncate(time.Second)
+
+		// The collector withholds the snapshot until it's refreshed, same as if refreshing had kept failing
+		if globalstate.IsStale(cached.UpdatedAt) {
+			log.Warnf("loaded %d applications last refreshed %s ago, older than metrics.prune_interval, their metrics are withheld until the next refresh", len(cached.Applications), age)
+		} else {
+			log.Infof("loaded %d stale applications, last refreshed %s ago", len(cached.Applications), age)
+		}
+	}
+}
+
+func readCacheFile(path string) (cacheFile, error) {
+	var contents cacheFile
+
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return contents, err
+	}
+
+	if err := json.Unmarshal(bytes, &contents); err != nil {
+		return contents, err
+	}
+
+	if contents.Version != cacheFileVersion {
+		return contents, fmt.Errorf("unsupported version %d, expected %d", contents.Version, cacheFileVersion)
+	}
+
+	return contents, nil
+}
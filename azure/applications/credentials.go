@@ -0,0 +1,316 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package applications
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	datatypes "azure_app_exporter/azure/applications/dataTypes"
+	fromswaggerui "azure_app_exporter/fromSwaggerUi"
+	globalstate "azure_app_exporter/globalState"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	credentialTypePassword    = "password"
+	credentialTypeCertificate = "certificate"
+)
+
+// @summary Show the passwords and certificates of all Azure applications cached in the exporter (truncated in Swagger UI to 50 entries)
+// @description Show the passwords and certificates of all Azure applications cached in the exporter, one row per credential (truncated in Swagger UI to 50 entries)
+// @description
+// @description Call this endpoint outside Swagger UI to see full response
+// @description
+// @description Every filter that is set has to match. Credentials without an end date sort as if they expire last.
+// @tags credentials
+// @param tenant_id query string false "Only show credentials of this tenant"
+// @param type query string false "Only show credentials of this type" Enums(password, certificate)
+// @param display_name query string false "Case insensitive substring of the credential's display name, or a regular expression enclosed in slashes like /^ci-/"
+// @param app_display_name query string false "Case insensitive substring of the application's display name, or a regular expression enclosed in slashes like /^prod-/"
+// @param app_id query string false "Only show credentials of the application with this client ID"
+// @param expiring_within query string false "Only show credentials expiring within this duration, like 30d or 12h"
+// @param expired query bool false "Only show expired (true) or not yet expired (false) credentials"
+// @param no_end_date query bool false "Only show credentials that never expire (true) or that do (false)"
+// @param sort query string false "Sort by this column, prefixed with - for descending order" Enums(end_date, -end_date, start_date, -start_date, display_name, -display_name, app_display_name, -app_display_name) default(end_date)
+// @produce json
+// @success 200 {array} datatypes.ApplicationCredential
+// @failure 400 "Invalid filter or sort"
+// @failure 404 "Unknown tenant"
+// @router /api/credentials [get]
+func AllCredentials(c echo.Context) error {
+	tenants, ok := globalstate.SelectTenants(c.QueryParam("tenant_id"))
+	if !ok {
+		return c.NoContent(http.StatusNotFound)
+	}
+
+	filter, err := parseCredentialFilter(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	compare, err := parseCredentialSort(c.QueryParam("sort"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	credentials := make([]datatypes.ApplicationCredential, 0)
+	now := time.Now()
+
+	for _, tenant := range tenants {
+		tenant.Applications.RwLock.RLock()
+		for _, application := range tenant.Applications.Value {
+			for _, credential := range applicationCredentials(tenant.Id, application, now) {
+				if filter.matches(credential, now) {
+					credentials = append(credentials, credential)
+				}
+			}
+		}
+		tenant.Applications.RwLock.RUnlock()
+	}
+
+	slices.SortFunc(credentials, compare)
+
+	if _, fromUi := c.Request().Header[fromswaggerui.HeaderName]; fromUi && len(credentials) > 50 {
+		credentials = credentials[:50]
+	}
+
+	return c.JSON(http.StatusOK, credentials)
+}
+
+// @summary Show a password or certificate of an Azure application by key ID
+// @description Show a password or certificate of an Azure application by key ID, which is what shows up in the audit logs
+// @tags credentials
+// @param keyId path string true "Key ID of the credential to lookup"
+// @param tenant_id query string false "Only lookup the credential in this tenant"
+// @produce json
+// @success 200 {object} datatypes.ApplicationCredential
+// @failure 404 "Unknown credential or tenant"
+// @router /api/credentials/{keyId} [get]
+func CredentialByKeyId(c echo.Context) error {
+	tenants, ok := globalstate.SelectTenants(c.QueryParam("tenant_id"))
+	if !ok {
+		return c.NoContent(http.StatusNotFound)
+	}
+
+	keyId := c.Param("keyId")
+	now := time.Now()
+
+	for _, tenant := range tenants {
+		tenant.Applications.RwLock.RLock()
+		for _, application := range tenant.Applications.Value {
+			for _, credential := range applicationCredentials(tenant.Id, application, now) {
+				if strings.EqualFold(credential.KeyId, keyId) {
+					tenant.Applications.RwLock.RUnlock()
+					return c.JSON(http.StatusOK, credential)
+				}
+			}
+		}
+		tenant.Applications.RwLock.RUnlock()
+	}
+
+	return c.NoContent(http.StatusNotFound)
+}
+
+// Flatten the passwords and certificates of application into rows
+func applicationCredentials(tenantId string, application datatypes.AzureApplication, now time.Time) []datatypes.ApplicationCredential {
+	credentials := make([]datatypes.ApplicationCredential, 0, len(application.PasswordCredentials)+len(application.KeyCredentials))
+
+	row := func(keyId string, credentialType string, displayName *string, start *datatypes.UtcTime, end *datatypes.UtcTime) datatypes.ApplicationCredential {
+		credential := datatypes.ApplicationCredential{
+			KeyId:                  keyId,
+			Type:                   credentialType,
+			DisplayName:            displayName,
+			TenantId:               tenantId,
+			ApplicationId:          application.Id,
+			AppId:                  application.AppId,
+			ApplicationDisplayName: application.DisplayName,
+			StartDateTime:          start,
+			EndDateTime:            end,
+		}
+
+		if end != nil {
+			remaining := int64(end.Sub(now) / time.Second)
+			credential.RemainingSeconds = &remaining
+		}
+
+		return credential
+	}
+
+	for _, password := range application.PasswordCredentials {
+		credentials = append(credentials, row(password.KeyId, credentialTypePassword, password.DisplayName, password.StartDateTime, password.EndDateTime))
+	}
+	for _, certificate := range application.KeyCredentials {
+		credentials = append(credentials, row(certificate.KeyId, credentialTypeCertificate, certificate.DisplayName, certificate.StartDateTime, certificate.EndDateTime))
+	}
+
+	return credentials
+}
+
+// Query parameters of /api/credentials, every parameter that is set has to match
+type credentialFilter struct {
+	credentialType string
+	displayName    func(string) bool
+	appDisplayName func(string) bool
+	appId          string
+	expiringWithin *time.Duration
+	expired        *bool
+	noEndDate      *bool
+}
+
+func parseCredentialFilter(c echo.Context) (credentialFilter, error) {
+	var (
+		filter credentialFilter
+		err    error
+	)
+
+	filter.credentialType = c.QueryParam("type")
+	if filter.credentialType != "" && filter.credentialType != credentialTypePassword && filter.credentialType != credentialTypeCertificate {
+		return filter, fmt.Errorf("invalid query parameter type %q, expected %s or %s", filter.credentialType, credentialTypePassword, credentialTypeCertificate)
+	}
+
+	if filter.displayName, err = parseDisplayNameParam(c, "display_name"); err != nil {
+		return filter, err
+	}
+	if filter.appDisplayName, err = parseDisplayNameParam(c, "app_display_name"); err != nil {
+		return filter, err
+	}
+
+	filter.appId = c.QueryParam("app_id")
+
+	if filter.expiringWithin, err = parseDurationParam(c, "expiring_within"); err != nil {
+		return filter, err
+	}
+	if filter.expired, err = parseBoolParam(c, "expired"); err != nil {
+		return filter, err
+	}
+	if filter.noEndDate, err = parseBoolParam(c, "no_end_date"); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+func (f credentialFilter) matches(credential datatypes.ApplicationCredential, now time.Time) bool {
+	matchesName := func(match func(string) bool, name *string) bool {
+		return match == nil || (name != nil && match(*name))
+	}
+
+	switch {
+	case f.credentialType != "" && credential.Type != f.credentialType:
+		return false
+	case !matchesName(f.displayName, credential.DisplayName):
+		return false
+	case !matchesName(f.appDisplayName, credential.ApplicationDisplayName):
+		return false
+	case f.appId != "" && !strings.EqualFold(credential.AppId, f.appId):
+		return false
+	case f.noEndDate != nil && (credential.EndDateTime == nil) != *f.noEndDate:
+		return false
+	}
+
+	end := credential.EndDateTime
+
+	if f.expiringWithin != nil && (end == nil || end.Before(now) || !end.Before(now.Add(*f.expiringWithin))) {
+		return false
+	}
+
+	if f.expired != nil && (end != nil && end.Before(now)) != *f.expired {
+		return false
+	}
+
+	return true
+}
+
+// Orders the credentials by one column, missing start dates sort first and missing end dates last
+var credentialSortColumns = map[string]func(a, b datatypes.ApplicationCredential) int{
+	"end_date": func(a, b datatypes.ApplicationCredential) int {
+		return compareTimes(a.EndDateTime, b.EndDateTime, 1)
+	},
+	"start_date": func(a, b datatypes.ApplicationCredential) int {
+		return compareTimes(a.StartDateTime, b.StartDateTime, -1)
+	},
+	"display_name": func(a, b datatypes.ApplicationCredential) int {
+		return compareNames(a.DisplayName, b.DisplayName)
+	},
+	"app_display_name": func(a, b datatypes.ApplicationCredential) int {
+		return compareNames(a.ApplicationDisplayName, b.ApplicationDisplayName)
+	},
+}
+
+// Parse a sort column like end_date, or -end_date for descending order. Ties are broken by key ID.
+func parseCredentialSort(value string) (func(a, b datatypes.ApplicationCredential) int, error) {
+	if value == "" {
+		value = "end_date"
+	}
+
+	column, descending := strings.CutPrefix(value, "-")
+
+	compare, ok := credentialSortColumns[column]
+	if !ok {
+		columns := make([]string, 0, len(credentialSortColumns))
+		for name := range credentialSortColumns {
+			columns = append(columns, name)
+		}
+		slices.Sort(columns)
+
+		return nil, fmt.Errorf("invalid query parameter sort %q, expected one of %v optionally prefixed with -", value, columns)
+	}
+
+	return func(a, b datatypes.ApplicationCredential) int {
+		order := compare(a, b)
+		if descending {
+			order = -order
+		}
+
+		return cmp.Or(order, cmp.Compare(a.KeyId, b.KeyId))
+	}, nil
+}
+
+// Compare two optional times, a missing one sorts like it's infinitely far in the direction of missing (1 or -1)
+func compareTimes(a, b *datatypes.UtcTime, missing int) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return missing
+	case b == nil:
+		return -missing
+	}
+
+	return a.Compare(b.Time)
+}
+
+func compareNames(a, b *string) int {
+	var aName, bName string
+	if a != nil {
+		aName = *a
+	}
+	if b != nil {
+		bName = *b
+	}
+
+	return cmp.Compare(strings.ToLower(aName), strings.ToLower(bName))
+}
@@ -20,6 +20,7 @@
 package datatypes
 
 import (
+	"encoding/json"
 	"math"
 	"time"
 )
@@ -30,17 +31,29 @@ type AzureApplications struct {
 	Value    []AzureApplication `json:"value"`
 }
 
+// https://learn.microsoft.com/en-us/graph/api/application-delta?view=graph-rest-1.0#response
+// Entries are kept raw, since a changed application may only carry the properties that changed
+type AzureApplicationsDelta struct {
+	NextLink  *string           `json:"@odata.nextLink"`
+	DeltaLink *string           `json:"@odata.deltaLink"`
+	Value     []json.RawMessage `json:"value"`
+}
+
 type AzureApplication struct {
 	Id                  string               `json:"id"                  validate:"required" extensions:"x-order=1"`
 	AppId               string               `json:"appId"               validate:"required" extensions:"x-order=2"`
 	DisplayName         *string              `json:"displayName"                             extensions:"x-order=3,x-nullable"`
 	PasswordCredentials []PasswordCredential `json:"passwordCredentials" validate:"required" extensions:"x-order=4"`
+	KeyCredentials      []KeyCredential      `json:"keyCredentials"      validate:"required" extensions:"x-order=5"`
 }
 
+// https://learn.microsoft.com/en-us/graph/api/resources/passwordcredential?view=graph-rest-1.0#properties
 type PasswordCredential struct {
-	KeyId       string   `json:"keyId"       validate:"required" extensions:"x-order=1"`
-	DisplayName *string  `json:"displayName"                     extensions:"x-order=2,x-nullable"`
-	EndDateTime *UtcTime `json:"endDateTime"                     extensions:"x-order=3,x-nullable" swaggertype:"string" format:"date-time"`
+	KeyId         string   `json:"keyId"         validate:"required" extensions:"x-order=1"`
+	DisplayName   *string  `json:"displayName"                       extensions:"x-order=2,x-nullable"`
+	Hint          *string  `json:"hint"                              extensions:"x-order=3,x-nullable" example:"Zx8"`
+	StartDateTime *UtcTime `json:"startDateTime"                     extensions:"x-order=4,x-nullable" swaggertype:"string" format:"date-time"`
+	EndDateTime   *UtcTime `json:"endDateTime"                       extensions:"x-order=5,x-nullable" swaggertype:"string" format:"date-time"`
 }
 
 // Return the remaining seconds until the password credential expires
@@ -52,3 +65,70 @@ func (p PasswordCredential) RemainingSeconds() float64 {
 
 	return time.Until(p.EndDateTime.Time).Seconds()
 }
+
+// Return true if the password credential has started and has not yet expired
+// A missing start or end time is treated as unbounded on that side
+func (p PasswordCredential) IsValid() bool {
+	now := time.Now()
+
+	if p.StartDateTime != nil && now.Before(p.StartDateTime.Time) {
+		return false
+	}
+
+	return p.EndDateTime == nil || now.Before(p.EndDateTime.Time)
+}
+
+// Return the seconds elapsed since the password credential became valid
+// Negative for a credential that starts in the future, NaN if a start time is not set
+func (p PasswordCredential) AgeSeconds() float64 {
+	if p.StartDateTime == nil {
+		return math.NaN()
+	}
+
+	return time.Since(p.StartDateTime.Time).Seconds()
+}
+
+// https://learn.microsoft.com/en-us/graph/api/resources/keycredential?view=graph-rest-1.0#properties
+type KeyCredential struct {
+	KeyId               string   `json:"keyId"               validate:"required" extensions:"x-order=1"`
+	DisplayName         *string  `json:"displayName"                             extensions:"x-order=2,x-nullable"`
+	Type                *string  `json:"type"                                    extensions:"x-order=3,x-nullable" example:"AsymmetricX509Cert"`
+	Usage               *string  `json:"usage"                                   extensions:"x-order=4,x-nullable" example:"Verify"`
+	StartDateTime       *UtcTime `json:"startDateTime"                           extensions:"x-order=5,x-nullable" swaggertype:"string" format:"date-time"`
+	EndDateTime         *UtcTime `json:"endDateTime"                             extensions:"x-order=6,x-nullable" swaggertype:"string" format:"date-time"`
+	CustomKeyIdentifier *string  `json:"customKeyIdentifier"                     extensions:"x-order=7,x-nullable"`
+}
+
+// Return the remaining seconds until the key credential expires
+// If an end time is not set, return positive infinity
+func (k KeyCredential) RemainingSeconds() float64 {
+	if k.EndDateTime == nil {
+		return math.Inf(1)
+	}
+
+	return time.Until(k.EndDateTime.Time).Seconds()
+}
+
+// A password or certificate of a cached application, flattened with its parent for /api/credentials
+type ApplicationCredential struct {
+	KeyId                  string   `json:"keyId"                  validate:"required" extensions:"x-order=1"`
+	Type                   string   `json:"type"                   validate:"required" extensions:"x-order=2" enums:"password,certificate"`
+	DisplayName            *string  `json:"displayName"                                extensions:"x-order=3,x-nullable"`
+	TenantId               string   `json:"tenantId"               validate:"required" extensions:"x-order=4"`
+	ApplicationId          string   `json:"applicationId"          validate:"required" extensions:"x-order=5"`
+	AppId                  string   `json:"appId"                  validate:"required" extensions:"x-order=6"`
+	ApplicationDisplayName *string  `json:"applicationDisplayName"                     extensions:"x-order=7,x-nullable"`
+	StartDateTime          *UtcTime `json:"startDateTime"                              extensions:"x-order=8,x-nullable" swaggertype:"string" format:"date-time"`
+	EndDateTime            *UtcTime `json:"endDateTime"                                extensions:"x-order=9,x-nullable" swaggertype:"string" format:"date-time"`
+	// Negative once expired, null if the credential never expires
+	RemainingSeconds *int64 `json:"remainingSeconds" extensions:"x-order=10,x-nullable"`
+}
+
+// The string behind s, or empty if it's not set, e.g. for metric labels
+func DerefOrDefault(s *string) string {
+	if s != nil {
+		return *s
+	}
+
+	return ""
+}
@@ -49,3 +49,12 @@ func (u *UtcTime) UnmarshalJSON(bytes []byte) error {
 func (u UtcTime) MarshalJSON() ([]byte, error) {
 	return u.Time.MarshalJSON()
 }
+
+// The time formatted by String, or empty if it's not set, e.g. for metric labels
+func DerefOrDefaultUtcTime(u *UtcTime) string {
+	if u != nil {
+		return u.String()
+	}
+
+	return ""
+}
@@ -0,0 +1,197 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package applications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"time"
+
+	datatypes "azure_app_exporter/azure/applications/dataTypes"
+	globalstate "azure_app_exporter/globalState"
+
+	"github.com/carlmjohnson/requests"
+)
+
+// Keeps the cached applications of a tenant in sync through the delta query
+// https://learn.microsoft.com/en-us/graph/api/application-delta?view=graph-rest-1.0
+type deltaSyncer struct {
+	tenant *globalstate.Tenant
+	fetch  func(ctx context.Context, url string, response any) error
+}
+
+func (d *deltaSyncer) sync(ctx context.Context) error {
+	d.tenant.Applications.RwLock.RLock()
+	deltaLink, fullSyncAt, applications := d.tenant.Applications.DeltaLink, d.tenant.Applications.FullSyncAt, d.tenant.Applications.Value
+	d.tenant.Applications.RwLock.RUnlock()
+
+	resyncInterval := globalstate.Settings().Applications.FullResyncInterval.Duration
+
+	if deltaLink != "" && time.Since(fullSyncAt) < resyncInterval {
+		err := d.syncChanges(ctx, deltaLink, applications)
+
+		// https://learn.microsoft.com/en-us/graph/delta-query-overview#synchronization-reset
+		if !requests.HasStatusErr(err, http.StatusGone) {
+			return err
+		}
+
+		d.tenant.Logger("applications").Warn("delta token of the azure applications expired, falling back to a full crawl")
+	}
+
+	return d.syncAll(ctx)
+}
+
+// Crawl the whole delta query and replace the cache with its result
+func (d *deltaSyncer) syncAll(ctx context.Context) error {
+	entries, deltaLink, err := d.crawl(
+		ctx,
+		fmt.Sprintf(
+			"%s/delta?$select=%s",
+			d.tenant.Settings().ApplicationsUrl(globalstate.Settings().Applications),
+			applicationFields,
+		),
+	)
+	if err != nil {
+		return err
+	}
+
+	applications := make(map[string]datatypes.AzureApplication, len(entries))
+	if _, _, err := applyDelta(applications, entries); err != nil {
+		return err
+	}
+
+	storeApplications(d.tenant, applications, deltaLink, true)
+
+	return nil
+}
+
+// Poll the changes since the previous round and apply them on a copy of the cache
+func (d *deltaSyncer) syncChanges(ctx context.Context, deltaLink string, current map[string]datatypes.AzureApplication) error {
+	entries, deltaLink, err := d.crawl(ctx, deltaLink)
+	if err != nil {
+		return err
+	}
+
+	applications := maps.Clone(current)
+	changed, removed, err := applyDelta(applications, entries)
+	if err != nil {
+		return err
+	}
+
+	d.tenant.Logger("applications").Debugf("applied %d changed and %d removed azure applications", changed, removed)
+
+	storeApplications(d.tenant, applications, deltaLink, false)
+
+	return nil
+}
+
+// Follow the next links from url until the last page, which carries the delta link for the next round
+func (d *deltaSyncer) crawl(ctx context.Context, url string) ([]json.RawMessage, string, error) {
+	var entries []json.RawMessage
+
+	for {
+		var response datatypes.AzureApplicationsDelta
+		if err := d.fetch(ctx, url, &response); err != nil {
+			return nil, "", err
+		}
+
+		entries = append(entries, response.Value...)
+
+		switch {
+		case response.NextLink != nil:
+			url = *response.NextLink
+		case response.DeltaLink != nil:
+			return entries, *response.DeltaLink, nil
+		default:
+			return nil, "", errors.New("delta response has neither a next link nor a delta link")
+		}
+	}
+}
+
+// Apply delta entries to applications in place, returning how many were added or changed and how many were removed
+func applyDelta(applications map[string]datatypes.AzureApplication, entries []json.RawMessage) (int, int, error) {
+	changed, removed := 0, 0
+
+	for _, entry := range entries {
+		var header struct {
+			Id      string           `json:"id"`
+			Removed *json.RawMessage `json:"@removed"`
+		}
+		if err := json.Unmarshal(entry, &header); err != nil {
+			return 0, 0, err
+		}
+
+		if header.Removed != nil {
+			delete(applications, header.Id)
+			removed++
+			continue
+		}
+
+		var existing *datatypes.AzureApplication
+		if application, ok := applications[header.Id]; ok {
+			existing = &application
+		}
+
+		application, err := mergeApplication(existing, entry)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		applications[header.Id] = application
+		changed++
+	}
+
+	return changed, removed, nil
+}
+
+// Overlay the properties present in a delta entry on top of the cached application.
+// Goes through JSON, so the result shares no memory with the previous snapshot.
+func mergeApplication(existing *datatypes.AzureApplication, entry json.RawMessage) (datatypes.AzureApplication, error) {
+	fields := make(map[string]json.RawMessage)
+
+	if existing != nil {
+		previous, err := json.Marshal(existing)
+		if err != nil {
+			return datatypes.AzureApplication{}, err
+		}
+
+		if err := json.Unmarshal(previous, &fields); err != nil {
+			return datatypes.AzureApplication{}, err
+		}
+	}
+
+	if err := json.Unmarshal(entry, &fields); err != nil {
+		return datatypes.AzureApplication{}, err
+	}
+
+	merged, err := json.Marshal(fields)
+	if err != nil {
+		return datatypes.AzureApplication{}, err
+	}
+
+	var application datatypes.AzureApplication
+	err = json.Unmarshal(merged, &application)
+
+	return application, err
+}
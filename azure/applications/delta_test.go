@@ -0,0 +1,171 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package applications
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	datatypes "azure_app_exporter/azure/applications/dataTypes"
+)
+
+func TestApplyDelta(t *testing.T) {
+	const cached = `{
+		"a1": {"id": "a1", "appId": "app1", "displayName": "One",
+			"passwordCredentials": [{"keyId": "k1", "displayName": "pw", "endDateTime": "2030-01-01T00:00:00Z"}],
+			"keyCredentials": [{"keyId": "c1", "endDateTime": "2029-01-01T00:00:00Z"}]},
+		"a2": {"id": "a2", "appId": "app2", "displayName": "Two", "passwordCredentials": [], "keyCredentials": []}
+	}`
+
+	cases := []struct {
+		name        string
+		entries     []string
+		want        string
+		wantChanged int
+		wantRemoved int
+		wantErr     bool
+	}{
+		{
+			name:        "adds a new application",
+			entries:     []string{`{"id": "a3", "appId": "app3", "displayName": "Three", "passwordCredentials": [], "keyCredentials": []}`},
+			want:        `{"a3": {"id": "a3", "appId": "app3", "displayName": "Three", "passwordCredentials": [], "keyCredentials": []}}`,
+			wantChanged: 1,
+		},
+		{
+			name:        "overlays a partial entry",
+			entries:     []string{`{"id": "a1", "displayName": "One Renamed"}`},
+			want:        `{"a1": {"id": "a1", "appId": "app1", "displayName": "One Renamed", "passwordCredentials": [{"keyId": "k1", "displayName": "pw", "endDateTime": "2030-01-01T00:00:00Z"}], "keyCredentials": [{"keyId": "c1", "endDateTime": "2029-01-01T00:00:00Z"}]}}`,
+			wantChanged: 1,
+		},
+		{
+			name:        "replaces a credential list as a whole",
+			entries:     []string{`{"id": "a1", "passwordCredentials": []}`},
+			want:        `{"a1": {"id": "a1", "appId": "app1", "displayName": "One", "passwordCredentials": [], "keyCredentials": [{"keyId": "c1", "endDateTime": "2029-01-01T00:00:00Z"}]}}`,
+			wantChanged: 1,
+		},
+		{
+			name:        "removes an application",
+			entries:     []string{`{"id": "a2", "@removed": {"reason": "deleted"}}`},
+			want:        `{"a2": null}`,
+			wantRemoved: 1,
+		},
+		{
+			name:        "ignores removing an unknown application",
+			entries:     []string{`{"id": "a9", "@removed": {"reason": "changed"}}`},
+			want:        `{}`,
+			wantRemoved: 1,
+		},
+		{
+			name: "merges an id repeated across pages in order",
+			entries: []string{
+				`{"id": "a2", "displayName": "Two Renamed"}`,
+				`{"id": "a2", "passwordCredentials": [{"keyId": "k2", "endDateTime": null}]}`,
+			},
+			want:        `{"a2": {"id": "a2", "appId": "app2", "displayName": "Two Renamed", "passwordCredentials": [{"keyId": "k2", "endDateTime": null}], "keyCredentials": []}}`,
+			wantChanged: 2,
+		},
+		{
+			name: "removes and adds back an id across pages",
+			entries: []string{
+				`{"id": "a2", "@removed": {"reason": "changed"}}`,
+				`{"id": "a2", "appId": "app2", "displayName": "Two Again"}`,
+			},
+			want:        `{"a2": {"id": "a2", "appId": "app2", "displayName": "Two Again", "passwordCredentials": null, "keyCredentials": null}}`,
+			wantChanged: 1,
+			wantRemoved: 1,
+		},
+		{
+			name:    "rejects an invalid entry",
+			entries: []string{`{"id": "a1", "displayName": 5}`},
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			applications := make(map[string]datatypes.AzureApplication)
+			if err := json.Unmarshal([]byte(cached), &applications); err != nil {
+				t.Fatal(err)
+			}
+
+			entries := make([]json.RawMessage, len(c.entries))
+			for i, entry := range c.entries {
+				entries[i] = json.RawMessage(entry)
+			}
+
+			changed, removed, err := applyDelta(applications, entries)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("err = %v, want error %t", err, c.wantErr)
+			}
+			if c.wantErr {
+				return
+			}
+			if changed != c.wantChanged || removed != c.wantRemoved {
+				t.Errorf("changed, removed = %d, %d, want %d, %d", changed, removed, c.wantChanged, c.wantRemoved)
+			}
+
+			// Only the listed ids are checked, a null one must be gone
+			var want map[string]*datatypes.AzureApplication
+			if err := json.Unmarshal([]byte(c.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			for id, wantApplication := range want {
+				got, ok := applications[id]
+				switch {
+				case wantApplication == nil && ok:
+					t.Errorf("%s was not removed", id)
+				case wantApplication != nil && !ok:
+					t.Errorf("%s is missing", id)
+				case wantApplication != nil && !reflect.DeepEqual(got, *wantApplication):
+					t.Errorf("%s = %s, want %s", id, marshal(t, got), marshal(t, *wantApplication))
+				}
+			}
+		})
+	}
+}
+
+func TestMergeApplicationDoesNotShareMemory(t *testing.T) {
+	var existing datatypes.AzureApplication
+	if err := json.Unmarshal([]byte(`{"id": "a1", "passwordCredentials": [{"keyId": "k1", "displayName": "pw"}]}`), &existing); err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := mergeApplication(&existing, json.RawMessage(`{"id": "a1"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*merged.PasswordCredentials[0].DisplayName = "changed"
+	if *existing.PasswordCredentials[0].DisplayName != "pw" {
+		t.Error("the merged application shares memory with the cached one")
+	}
+}
+
+func marshal(t *testing.T, application datatypes.AzureApplication) string {
+	t.Helper()
+
+	contents, err := json.Marshal(application)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(contents)
+}
@@ -0,0 +1,193 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package applications
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	datatypes "azure_app_exporter/azure/applications/dataTypes"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Query parameters of /api/apps, every parameter that is set has to match
+type applicationFilter struct {
+	displayName    func(string) bool
+	appId          string
+	expiringWithin *time.Duration
+	expired        *bool
+	hasCredentials *bool
+	noEndDate      *bool
+}
+
+func parseApplicationFilter(c echo.Context) (applicationFilter, error) {
+	var (
+		filter applicationFilter
+		err    error
+	)
+
+	if filter.displayName, err = parseDisplayNameParam(c, "display_name"); err != nil {
+		return filter, err
+	}
+
+	filter.appId = c.QueryParam("app_id")
+
+	if filter.expiringWithin, err = parseDurationParam(c, "expiring_within"); err != nil {
+		return filter, err
+	}
+	if filter.expired, err = parseBoolParam(c, "expired"); err != nil {
+		return filter, err
+	}
+	if filter.hasCredentials, err = parseBoolParam(c, "has_credentials"); err != nil {
+		return filter, err
+	}
+	if filter.noEndDate, err = parseBoolParam(c, "no_end_date"); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+func (f applicationFilter) matches(application datatypes.AzureApplication, now time.Time) bool {
+	if f.displayName != nil && (application.DisplayName == nil || !f.displayName(*application.DisplayName)) {
+		return false
+	}
+
+	if f.appId != "" && !strings.EqualFold(application.AppId, f.appId) {
+		return false
+	}
+
+	endDates := credentialEndDates(application)
+
+	if f.hasCredentials != nil && (len(endDates) > 0) != *f.hasCredentials {
+		return false
+	}
+
+	if f.expiringWithin != nil && !anyEndDate(endDates, func(end time.Time) bool {
+		return !end.Before(now) && end.Before(now.Add(*f.expiringWithin))
+	}) {
+		return false
+	}
+
+	if f.expired != nil && anyEndDate(endDates, func(end time.Time) bool { return end.Before(now) }) != *f.expired {
+		return false
+	}
+
+	if f.noEndDate != nil && anyEndDate(endDates, nil) != *f.noEndDate {
+		return false
+	}
+
+	return true
+}
+
+// The end dates of every password and certificate of the application, nil for those without one
+func credentialEndDates(application datatypes.AzureApplication) []*datatypes.UtcTime {
+	endDates := make([]*datatypes.UtcTime, 0, len(application.PasswordCredentials)+len(application.KeyCredentials))
+
+	for _, password := range application.PasswordCredentials {
+		endDates = append(endDates, password.EndDateTime)
+	}
+	for _, certificate := range application.KeyCredentials {
+		endDates = append(endDates, certificate.EndDateTime)
+	}
+
+	return endDates
+}
+
+// Report whether any end date satisfies predicate, or with a nil predicate whether any end date is missing
+func anyEndDate(endDates []*datatypes.UtcTime, predicate func(time.Time) bool) bool {
+	for _, end := range endDates {
+		if end == nil {
+			if predicate == nil {
+				return true
+			}
+		} else if predicate != nil && predicate(end.Time) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// A case insensitive substring match, or a regular expression if the value is enclosed in slashes like /^prod-/
+func parseDisplayNameParam(c echo.Context, name string) (func(string) bool, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	if len(value) > 1 && strings.HasPrefix(value, "/") && strings.HasSuffix(value, "/") {
+		pattern, err := regexp.Compile(value[1 : len(value)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid query parameter %s -> %w", name, err)
+		}
+		return pattern.MatchString, nil
+	}
+
+	value = strings.ToLower(value)
+	return func(displayName string) bool {
+		return strings.Contains(strings.ToLower(displayName), value)
+	}, nil
+}
+
+func parseBoolParam(c echo.Context, name string) (*bool, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query parameter %s %q, expected true or false", name, value)
+	}
+
+	return &parsed, nil
+}
+
+func parseDurationParam(c echo.Context, name string) (*time.Duration, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := parseDuration(value)
+	if err != nil || parsed < 0 {
+		return nil, fmt.Errorf("invalid query parameter %s %q, expected a duration like 30d or 12h", name, value)
+	}
+
+	return &parsed, nil
+}
+
+// Like time.ParseDuration, with an additional d unit for whole days as in 30d
+func parseDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		count, err := strconv.ParseUint(days, 10, 16)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(count) * 24 * time.Hour, nil
+	}
+
+	return time.ParseDuration(value)
+}
@@ -20,42 +20,178 @@
 package applications
 
 import (
+	"azure_app_exporter/logging"
+
 	appmetrics "azure_app_exporter/appMetrics"
 	datatypes "azure_app_exporter/azure/applications/dataTypes"
 	globalstate "azure_app_exporter/globalState"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
-func derefOrDefault(s *string) string {
-	if s != nil {
-		return *s
+func unixSeconds(u *datatypes.UtcTime) float64 {
+	return float64(u.UnixMilli()) / 1000
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
 	}
 
-	return ""
+	return 0
 }
 
-func derefOrDefaultUtcTime(u *datatypes.UtcTime) string {
-	if u != nil {
-		return u.String()
+// Exports the credentials of the cached applications at scrape time, so the series always
+// mirror the latest snapshot and disappear together with the applications they belong to
+type collector struct{}
+
+func (collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- appmetrics.ApplicationPasswordSeconds
+	ch <- appmetrics.ApplicationPasswordExpiryTimestampSeconds
+	ch <- appmetrics.ApplicationPasswordValid
+	ch <- appmetrics.ApplicationPasswordAgeSeconds
+	ch <- appmetrics.ApplicationCertificateSeconds
+	ch <- appmetrics.ApplicationCertificateExpiryTimestampSeconds
+
+	if appmetrics.CompactLayout() {
+		ch <- appmetrics.ApplicationInfo
+		ch <- appmetrics.ApplicationPasswordInfo
+		ch <- appmetrics.ApplicationCertificateInfo
 	}
+}
 
-	return ""
+func (collector) Collect(ch chan<- prometheus.Metric) {
+	for _, tenant := range globalstate.Tenants {
+		// Only hold the lock to grab the snapshot, it's never mutated after being swapped in
+		tenant.Applications.RwLock.RLock()
+		applications, updatedAt := tenant.Applications.Value, tenant.Applications.UpdatedAt
+		tenant.Applications.RwLock.RUnlock()
+
+		// Withhold a snapshot older than [metrics] prune_interval, but keep it cached for the API and the next delta round
+		if globalstate.IsStale(updatedAt) {
+			pruned := appmetrics.CountSeries(func(ch chan<- prometheus.Metric) {
+				collectApplications(ch, tenant.Id, applications)
+			})
+			appmetrics.PrunedSeries.WithLabelValues(tenant.Id, "applications").Set(float64(pruned))
+			continue
+		}
+
+		appmetrics.PrunedSeries.WithLabelValues(tenant.Id, "applications").Set(0)
+		collectApplications(ch, tenant.Id, applications)
+	}
 }
 
-func UpdateApplicationsMetrics() {
-	globalstate.Applications.RwLock.RLock()
-	defer globalstate.Applications.RwLock.RUnlock()
+func collectApplications(ch chan<- prometheus.Metric, tenantId string, applications map[string]datatypes.AzureApplication) {
+	compact := appmetrics.CompactLayout()
 
-	for id, application := range globalstate.Applications.Value {
-		for _, password := range application.PasswordCredentials {
-			appmetrics.ApplicationPasswordSeconds.WithLabelValues(
+	for id, application := range applications {
+		if compact {
+			ch <- prometheus.MustNewConstMetric(
+				appmetrics.ApplicationInfo,
+				prometheus.GaugeValue,
+				1,
+				tenantId,
 				id,
 				application.AppId,
-				derefOrDefault(application.DisplayName),
-				password.KeyId,
-				derefOrDefault(password.DisplayName),
-				derefOrDefaultUtcTime(password.EndDateTime),
-			).
-				Set(password.RemainingSeconds())
+				datatypes.DerefOrDefault(application.DisplayName),
+			)
 		}
+
+		for _, password := range application.PasswordCredentials {
+			var labels []string
+
+			if compact {
+				labels = []string{tenantId, id, password.KeyId}
+
+				ch <- prometheus.MustNewConstMetric(
+					appmetrics.ApplicationPasswordInfo,
+					prometheus.GaugeValue,
+					1,
+					tenantId,
+					id,
+					password.KeyId,
+					datatypes.DerefOrDefault(password.DisplayName),
+					datatypes.DerefOrDefaultUtcTime(password.EndDateTime),
+				)
+			} else {
+				labels = []string{
+					tenantId,
+					id,
+					application.AppId,
+					datatypes.DerefOrDefault(application.DisplayName),
+					password.KeyId,
+					datatypes.DerefOrDefault(password.DisplayName),
+					datatypes.DerefOrDefaultUtcTime(password.EndDateTime),
+				}
+			}
+
+			ch <- prometheus.MustNewConstMetric(appmetrics.ApplicationPasswordSeconds, prometheus.GaugeValue, password.RemainingSeconds(), labels...)
+			ch <- prometheus.MustNewConstMetric(appmetrics.ApplicationPasswordValid, prometheus.GaugeValue, boolToFloat(password.IsValid()), labels...)
+
+			if password.EndDateTime != nil {
+				ch <- prometheus.MustNewConstMetric(appmetrics.ApplicationPasswordExpiryTimestampSeconds, prometheus.GaugeValue, unixSeconds(password.EndDateTime), labels...)
+			}
+
+			if password.StartDateTime != nil {
+				ch <- prometheus.MustNewConstMetric(appmetrics.ApplicationPasswordAgeSeconds, prometheus.GaugeValue, password.AgeSeconds(), labels...)
+			}
+		}
+
+		for _, certificate := range application.KeyCredentials {
+			var labels []string
+
+			if compact {
+				labels = []string{tenantId, id, certificate.KeyId}
+
+				ch <- prometheus.MustNewConstMetric(
+					appmetrics.ApplicationCertificateInfo,
+					prometheus.GaugeValue,
+					1,
+					tenantId,
+					id,
+					certificate.KeyId,
+					datatypes.DerefOrDefault(certificate.DisplayName),
+					datatypes.DerefOrDefaultUtcTime(certificate.EndDateTime),
+					datatypes.DerefOrDefault(certificate.Type),
+					datatypes.DerefOrDefault(certificate.Usage),
+				)
+			} else {
+				labels = []string{
+					tenantId,
+					id,
+					application.AppId,
+					datatypes.DerefOrDefault(application.DisplayName),
+					certificate.KeyId,
+					datatypes.DerefOrDefault(certificate.DisplayName),
+					datatypes.DerefOrDefaultUtcTime(certificate.EndDateTime),
+				}
+			}
+
+			ch <- prometheus.MustNewConstMetric(appmetrics.ApplicationCertificateSeconds, prometheus.GaugeValue, certificate.RemainingSeconds(), labels...)
+
+			if certificate.EndDateTime != nil {
+				ch <- prometheus.MustNewConstMetric(appmetrics.ApplicationCertificateExpiryTimestampSeconds, prometheus.GaugeValue, unixSeconds(certificate.EndDateTime), labels...)
+			}
+		}
+	}
+}
+
+// Report the size of a freshly swapped in applications snapshot
+func updateCacheMetrics(tenantId string, applications map[string]datatypes.AzureApplication) {
+	passwords, certificates := 0, 0
+	for _, application := range applications {
+		passwords += len(application.PasswordCredentials)
+		certificates += len(application.KeyCredentials)
+	}
+
+	appmetrics.ApplicationsCached.WithLabelValues(tenantId).Set(float64(len(applications)))
+	appmetrics.ApplicationCredentialsCached.WithLabelValues(tenantId, "password").Set(float64(passwords))
+	appmetrics.ApplicationCredentialsCached.WithLabelValues(tenantId, "certificate").Set(float64(certificates))
+}
+
+// Register the credential metrics collector, after appmetrics.Init
+func RegisterCollector() {
+	if err := prometheus.Register(collector{}); err != nil {
+		logging.Fatal(err)
 	}
 }
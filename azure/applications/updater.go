@@ -20,59 +20,99 @@
 package applications
 
 import (
-	"azure_app_exporter/logging"
 	"context"
 	"fmt"
 	"time"
 
 	appmetrics "azure_app_exporter/appMetrics"
+	appsettings "azure_app_exporter/appSettings"
 	datatypes "azure_app_exporter/azure/applications/dataTypes"
 	globalstate "azure_app_exporter/globalState"
+	"azure_app_exporter/tracing"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// Properties of the applications kept in the cache
+const applicationFields = "id,appId,displayName,createdDateTime,passwordCredentials,keyCredentials"
+
+// Swap in a new snapshot of the applications of a tenant
+func storeApplications(tenant *globalstate.Tenant, applications map[string]datatypes.AzureApplication, deltaLink string, fullSync bool) {
+	tenant.Applications.RwLock.Lock()
+	defer tenant.Applications.RwLock.Unlock()
+
+	tenant.Applications.Value = applications
+	tenant.Applications.UpdatedAt = time.Now()
+	tenant.Applications.DeltaLink = deltaLink
+	if fullSync {
+		tenant.Applications.FullSyncAt = tenant.Applications.UpdatedAt
+	}
+
+	updateCacheMetrics(tenant.Id, applications)
+
+	tenant.Logger("applications").Debugf("cached %d applications", len(applications))
+}
+
 // https://learn.microsoft.com/en-us/graph/query-parameters
 // https://learn.microsoft.com/en-us/graph/api/application-list?view=graph-rest-1.0
-func AzureApplicationsUpdater() {
+func AzureApplicationsUpdater(ctx context.Context, tenant *globalstate.Tenant) {
+	log := tenant.Logger("applications")
+
 	// This func is spawned in a thread simultaneously with another thread
 	// responsible for updating the api token, so we should wait for it to finish
-	for globalstate.AzureApiToken.Value == "" {
-		logging.Warn("azure api token not yet acquired, sleeping 5 seconds")
-		time.Sleep(5 * time.Second)
+	for tenant.AzureApiToken.Value == "" {
+		log.Warn("azure api token not yet acquired, sleeping 5 seconds")
+		if !globalstate.Sleep(ctx, 5*time.Second) {
+			return
+		}
 	}
 
 	httpClient := globalstate.HttpClient.Clone()
 
-	getApplications := func(url string) (datatypes.AzureApplications, error) {
-		logging.Debugf("calling with bearer token: %s", url)
+	fetch := func(ctx context.Context, url string, response any) (err error) {
+		log.With("url", url).Debug("calling with bearer token")
 
-		globalstate.AzureApiToken.RwLock.RLock()
-		defer globalstate.AzureApiToken.RwLock.RUnlock()
+		ctx, span := tracing.Tracer().Start(ctx, "fetch azure applications page", trace.WithAttributes(
+			tracing.TenantKey.String(tenant.Id),
+			semconv.URLFull(url),
+		))
+		defer func() {
+			tracing.RecordError(span, err)
+			span.End()
+		}()
 
-		var response datatypes.AzureApplications
-		err := httpClient.
-			BaseURL(url).
-			Bearer(globalstate.AzureApiToken.Value).
-			ToJSON(&response).
-			Fetch(context.Background())
+		// Don't hold the lock during the request, retries could block the token updater for a long time
+		tenant.AzureApiToken.RwLock.RLock()
+		token := tenant.AzureApiToken.Value
+		tenant.AzureApiToken.RwLock.RUnlock()
 
-		return response, err
+		return httpClient.
+			BaseURL(url).
+			Bearer(token).
+			ToJSON(response).
+			Fetch(ctx)
 	}
 
-	inner := func() error {
-		response, err := getApplications(
+	syncAll := func(ctx context.Context) error {
+		var response datatypes.AzureApplications
+		err := fetch(
+			ctx,
 			fmt.Sprintf(
-				"%s?$top=%d&$select=id,appId,displayName,createdDateTime,passwordCredentials",
-				globalstate.Settings.Applications.Url,
-				globalstate.Settings.Applications.ResultsPerPage,
+				"%s?$top=%d&$select=%s",
+				tenant.Settings().ApplicationsUrl(globalstate.Settings().Applications),
+				globalstate.Settings().Applications.ResultsPerPage,
+				applicationFields,
 			),
+			&response,
 		)
 		if err != nil {
 			return err
 		}
 
 		for response.NextLink != nil {
-			nextResponse, err := getApplications(*response.NextLink)
-			if err != nil {
+			var nextResponse datatypes.AzureApplications
+			if err := fetch(ctx, *response.NextLink, &nextResponse); err != nil {
 				return err
 			}
 
@@ -80,34 +120,58 @@ func AzureApplicationsUpdater() {
 			response.Value = append(response.Value, nextResponse.Value...)
 		}
 
-		globalstate.Applications.RwLock.Lock()
-		defer globalstate.Applications.RwLock.Unlock()
-
-		for k := range globalstate.Applications.Value {
-			delete(globalstate.Applications.Value, k)
-		}
-
+		applications := make(map[string]datatypes.AzureApplication, len(response.Value))
 		for _, application := range response.Value {
-			globalstate.Applications.Value[application.Id] = application
+			applications[application.Id] = application
 		}
 
-		logging.Debugf("cached %d applications", len(globalstate.Applications.Value))
+		storeApplications(tenant, applications, "", true)
 
 		return nil
 	}
 
+	inner := syncAll
+	if globalstate.Settings().Applications.SyncMode == appsettings.SyncModeDelta {
+		inner = (&deltaSyncer{tenant: tenant, fetch: fetch}).sync
+	}
+
+	// One trace per update, with a child span per page
+	update := func() error {
+		ctx, span := tracing.Tracer().Start(ctx, "update azure applications", trace.WithAttributes(tracing.TenantKey.String(tenant.Id)))
+		defer span.End()
+
+		err := inner(ctx)
+		tracing.RecordError(span, err)
+
+		return err
+	}
+
+	// Re-read on every call, so a reload changes the interval of the running sleep
+	refreshInterval := func() time.Duration {
+		return tenant.Settings().RefreshInterval(globalstate.Settings().Applications.CacheRefreshInterval).Duration
+	}
+
 	for {
 		start := time.Now()
 
-		if err := inner(); err == nil {
+		if err := update(); err == nil {
 			elapsed := time.Since(start)
-			appmetrics.ApplicationsSeconds.Observe(elapsed.Seconds())
-			logging.Infof("updated azure applications in %s, next update after %s", elapsed, globalstate.Settings.Applications.CacheRefreshInterval)
+			appmetrics.ApplicationsSeconds.WithLabelValues(tenant.Id).Observe(elapsed.Seconds())
+			appmetrics.ApplicationsLastSuccess.WithLabelValues(tenant.Id).SetToCurrentTime()
+			log.With("latency", elapsed, "next_update", refreshInterval()).Info("updated azure applications")
+
+			if err := saveCacheFile(); err != nil {
+				log.WithError(err).Error("failed saving the applications cache file")
+			}
+		} else if ctx.Err() != nil {
+			return
 		} else {
-			logging.Errorf("failed updating azure applications -> %s, new attempt after %s", err, globalstate.Settings.Applications.CacheRefreshInterval)
-			appmetrics.ApplicationsFailures.Inc()
+			log.WithError(err).With("next_update", refreshInterval()).Error("failed updating azure applications")
+			appmetrics.ApplicationsFailures.WithLabelValues(tenant.Id).Inc()
 		}
 
-		time.Sleep(globalstate.Settings.Applications.CacheRefreshInterval.Duration)
+		if !globalstate.SleepUntil(ctx, func() time.Time { return start.Add(refreshInterval()) }) {
+			return
+		}
 	}
 }
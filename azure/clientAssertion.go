@@ -0,0 +1,208 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package azure
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	appsettings "azure_app_exporter/appSettings"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+type clientCertificate struct {
+	chain []*x509.Certificate
+	key   *rsa.PrivateKey
+}
+
+// Load the certificate chain and private key from a PEM file (with the key in the same file or in key_path)
+// or from a PKCS#12 archive
+func loadClientCertificate(credentials appsettings.Credentials) (clientCertificate, error) {
+	var certificate clientCertificate
+
+	contents, err := os.ReadFile(*credentials.CertificatePath)
+	if err != nil {
+		return certificate, err
+	}
+
+	blocks := decodePemBlocks(contents)
+	if len(blocks) == 0 {
+		// Not PEM, so it has to be PKCS#12
+		return decodePkcs12Certificate(*credentials.CertificatePath, contents, string(credentials.CertificatePassword))
+	}
+
+	if credentials.KeyPath != nil {
+		keyContents, err := os.ReadFile(*credentials.KeyPath)
+		if err != nil {
+			return certificate, err
+		}
+		blocks = append(blocks, decodePemBlocks(keyContents)...)
+	}
+
+	for _, block := range blocks {
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return certificate, err
+			}
+			certificate.chain = append(certificate.chain, cert)
+		case "PRIVATE KEY", "RSA PRIVATE KEY":
+			if certificate.key, err = parseRsaPrivateKey(block.Bytes); err != nil {
+				return certificate, err
+			}
+		}
+	}
+
+	if len(certificate.chain) == 0 {
+		return certificate, errors.New("no certificate found in certificate_path")
+	}
+	if certificate.key == nil {
+		return certificate, errors.New("no private key found in certificate_path or key_path")
+	}
+
+	return certificate, nil
+}
+
+// Decode a PKCS#12 archive, which always carries its private key. The leaf certificate comes first in the chain.
+func decodePkcs12Certificate(path string, contents []byte, password string) (clientCertificate, error) {
+	key, leaf, caCertificates, err := pkcs12.DecodeChain(contents, password)
+	if err != nil {
+		return clientCertificate{}, fmt.Errorf("failed decoding %s as PEM or PKCS#12 -> %w", path, err)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return clientCertificate{}, fmt.Errorf("the private key in %s is not an RSA key", path)
+	}
+
+	return clientCertificate{chain: append([]*x509.Certificate{leaf}, caCertificates...), key: rsaKey}, nil
+}
+
+func decodePemBlocks(contents []byte) []*pem.Block {
+	var blocks []*pem.Block
+
+	for {
+		var block *pem.Block
+		if block, contents = pem.Decode(contents); block == nil {
+			return blocks
+		}
+		blocks = append(blocks, block)
+	}
+}
+
+func parseRsaPrivateKey(der []byte) (*rsa.PrivateKey, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	// Entra ID only accepts RS256 signed client assertions
+	if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+		return rsaKey, nil
+	}
+
+	return nil, fmt.Errorf("unsupported private key type %T, only RSA keys are supported", key)
+}
+
+// https://learn.microsoft.com/en-us/entra/identity-platform/certificate-credentials#assertion-format
+func newClientAssertion(credentials appsettings.Credentials, audience string) (string, error) {
+	certificate, err := loadClientCertificate(credentials)
+	if err != nil {
+		return "", err
+	}
+
+	thumbprint := sha1.Sum(certificate.chain[0].Raw)
+	header := map[string]any{
+		"alg": "RS256",
+		"typ": "JWT",
+		"x5t": base64.RawURLEncoding.EncodeToString(thumbprint[:]),
+	}
+
+	if credentials.CertificateHeader == appsettings.CertificateHeaderX5c {
+		chain := make([]string, 0, len(certificate.chain))
+		for _, cert := range certificate.chain {
+			chain = append(chain, base64.StdEncoding.EncodeToString(cert.Raw))
+		}
+		header["x5c"] = chain
+	}
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := map[string]any{
+		"aud": audience,
+		"iss": credentials.ClientId,
+		"sub": credentials.ClientId,
+		"jti": hex.EncodeToString(jti),
+		"nbf": now.Unix(),
+		"iat": now.Unix(),
+		"exp": now.Add(10 * time.Minute).Unix(),
+	}
+
+	encodeSegment := func(v any) (string, error) {
+		bytes, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return base64.RawURLEncoding.EncodeToString(bytes), nil
+	}
+
+	encodedHeader, err := encodeSegment(header)
+	if err != nil {
+		return "", err
+	}
+	encodedClaims, err := encodeSegment(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encodedHeader + "." + encodedClaims
+	digest := sha256.Sum256([]byte(signingInput))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, certificate.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,159 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package azure
+
+import (
+	"azure_app_exporter/logging"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	appsettings "azure_app_exporter/appSettings"
+
+	"github.com/carlmjohnson/requests"
+)
+
+// Fetch the client secret from an external secret store, keyed by [credentials.client_secret_provider] type
+var secretProviders = map[appsettings.SecretProviderType]func(ctx context.Context, httpClient *requests.Builder, credentials appsettings.Credentials) (string, error){
+	appsettings.SecretProviderAzureKeyVault:  keyVaultSecret,
+	appsettings.SecretProviderHashicorpVault: hashicorpVaultSecret,
+}
+
+// Resolve the client secret from whichever source is configured. This runs on every token refresh,
+// so a rotated secret is picked up without a restart.
+func resolveClientSecret(ctx context.Context, httpClient *requests.Builder, credentials appsettings.Credentials) (string, error) {
+	var (
+		secret string
+		err    error
+	)
+
+	switch {
+	case credentials.ClientSecretFile != nil:
+		var contents []byte
+		contents, err = os.ReadFile(*credentials.ClientSecretFile)
+		secret = strings.TrimSpace(string(contents))
+	case credentials.ClientSecretEnv != nil:
+		var ok bool
+		if secret, ok = os.LookupEnv(*credentials.ClientSecretEnv); !ok {
+			err = fmt.Errorf("env var %s is not set", *credentials.ClientSecretEnv)
+		}
+	case credentials.ClientSecretProvider != nil:
+		secret, err = secretProviders[credentials.ClientSecretProvider.Type](ctx, httpClient, credentials)
+	default:
+		secret = string(credentials.ClientSecret)
+	}
+
+	if err != nil {
+		return "", err
+	}
+	if secret == "" {
+		return "", errors.New("the client secret is empty")
+	}
+
+	return secret, nil
+}
+
+// https://learn.microsoft.com/en-us/rest/api/keyvault/secrets/get-secret/get-secret
+func keyVaultSecret(ctx context.Context, httpClient *requests.Builder, credentials appsettings.Credentials) (string, error) {
+	provider := *credentials.ClientSecretProvider
+	identity := credentials.KeyVaultIdentity()
+
+	var (
+		token authToken
+		err   error
+	)
+	if identity.Mode == appsettings.CredentialsModeWorkloadIdentity {
+		token, err = workloadIdentityToken(ctx, httpClient.Clone(), identity, provider.KeyVaultResource())
+	} else {
+		token, err = managedIdentityToken(ctx, httpClient, identity, provider.KeyVaultResource())
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed getting a key vault token -> %w", err)
+	}
+
+	requestUrl := fmt.Sprintf("%s/secrets/%s", strings.TrimRight(provider.VaultUrl, "/"), url.PathEscape(provider.SecretName))
+	if provider.SecretVersion != nil {
+		requestUrl += "/" + url.PathEscape(*provider.SecretVersion)
+	}
+	logging.With("component", "token", "url", requestUrl).Debug("fetching client secret from key vault")
+
+	var response struct {
+		Value string `json:"value"`
+	}
+	err = httpClient.
+		Clone().
+		BaseURL(requestUrl).
+		Param("api-version", "7.4").
+		Bearer(token.AccessToken).
+		ToJSON(&response).
+		Fetch(ctx)
+
+	return response.Value, err
+}
+
+// https://developer.hashicorp.com/vault/api-docs/secret/kv/kv-v2#read-secret-version
+// https://developer.hashicorp.com/vault/api-docs/secret/kv/kv-v1#read-secret
+func hashicorpVaultSecret(ctx context.Context, httpClient *requests.Builder, credentials appsettings.Credentials) (string, error) {
+	provider := *credentials.ClientSecretProvider
+
+	// A token file is usually written by the Vault agent, which renews it, so it has to be read again on every refresh
+	token := os.Getenv("VAULT_TOKEN")
+	if provider.TokenFile != nil {
+		contents, err := os.ReadFile(*provider.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("failed reading vault token file -> %w", err)
+		}
+		token = strings.TrimSpace(string(contents))
+	}
+
+	requestUrl := fmt.Sprintf("%s/v1/%s", provider.ResolvedAddress(), strings.TrimLeft(provider.Path, "/"))
+	logging.With("component", "token", "url", requestUrl).Debug("fetching client secret from vault")
+
+	request := httpClient.
+		Clone().
+		BaseURL(requestUrl).
+		Header("X-Vault-Token", token)
+	if provider.Namespace != nil {
+		request.Header("X-Vault-Namespace", *provider.Namespace)
+	}
+
+	// KV v2 nests the secret's keys one level deeper than KV v1
+	var response struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := request.ToJSON(&response).Fetch(ctx); err != nil {
+		return "", err
+	}
+
+	data := response.Data
+	if nested, ok := data["data"].(map[string]any); ok {
+		data = nested
+	}
+
+	secret, ok := data[provider.Key].(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no string key %s", provider.Path, provider.Key)
+	}
+
+	return secret, nil
+}
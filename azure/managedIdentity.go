@@ -0,0 +1,107 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package azure
+
+import (
+	"azure_app_exporter/logging"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	appsettings "azure_app_exporter/appSettings"
+
+	"github.com/carlmjohnson/requests"
+)
+
+const (
+	imdsEndpoint         = "http://169.254.169.254/metadata/identity/oauth2/token"
+	imdsApiVersion       = "2018-02-01"
+	appServiceApiVersion = "2019-08-01"
+)
+
+// Both endpoints return numbers as strings, and the App Service endpoint only returns expires_on
+type managedIdentityResponse struct {
+	AccessToken string      `json:"access_token"`
+	ExpiresIn   json.Number `json:"expires_in"`
+	ExpiresOn   json.Number `json:"expires_on"`
+}
+
+func (m managedIdentityResponse) toAuthToken() (authToken, error) {
+	token := authToken{AccessToken: m.AccessToken}
+
+	if m.ExpiresIn != "" {
+		expiresIn, err := strconv.ParseUint(m.ExpiresIn.String(), 10, 64)
+		if err != nil {
+			return token, fmt.Errorf("invalid expires_in %s -> %w", m.ExpiresIn, err)
+		}
+		token.ExpiresIn = expiresIn
+		return token, nil
+	}
+
+	expiresOn, err := strconv.ParseInt(m.ExpiresOn.String(), 10, 64)
+	if err != nil {
+		return token, fmt.Errorf("invalid expires_on %s -> %w", m.ExpiresOn, err)
+	}
+	token.ExpiresIn = uint64(max(time.Until(time.Unix(expiresOn, 0)).Seconds(), 0))
+
+	return token, nil
+}
+
+// https://learn.microsoft.com/en-us/entra/identity/managed-identities-azure-resources/how-to-use-vm-token#get-a-token-using-http
+// https://learn.microsoft.com/en-us/azure/app-service/overview-managed-identity#rest-endpoint-reference
+// resource is the API the token is for, like the Graph endpoint
+func managedIdentityToken(ctx context.Context, httpClient *requests.Builder, credentials appsettings.Credentials, resource string) (authToken, error) {
+	identityEndpoint, hasIdentityEndpoint := os.LookupEnv("IDENTITY_ENDPOINT")
+	identityHeader, hasIdentityHeader := os.LookupEnv("IDENTITY_HEADER")
+	appService := hasIdentityEndpoint && hasIdentityHeader
+
+	requestUrl := imdsEndpoint
+	if appService {
+		requestUrl = identityEndpoint
+	}
+	if credentials.ManagedIdentityEndpoint != nil {
+		requestUrl = *credentials.ManagedIdentityEndpoint
+	}
+
+	// Clone so query params don't accumulate on the shared builder between refreshes
+	request := httpClient.
+		Clone().
+		BaseURL(requestUrl).
+		Param("resource", resource).
+		ParamOptional("client_id", credentials.ManagedIdentityClientId())
+
+	if appService {
+		logging.With("component", "token", "url", requestUrl).Debug("calling app service managed identity endpoint")
+		request.Param("api-version", appServiceApiVersion).Header("X-IDENTITY-HEADER", identityHeader)
+	} else {
+		logging.With("component", "token", "url", requestUrl).Debug("calling instance metadata managed identity endpoint")
+		request.Param("api-version", imdsApiVersion).Header("Metadata", "true")
+	}
+
+	var response managedIdentityResponse
+	if err := request.ToJSON(&response).Fetch(ctx); err != nil {
+		return authToken{}, err
+	}
+
+	return response.toAuthToken()
+}
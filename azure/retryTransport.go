@@ -0,0 +1,122 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package azure
+
+import (
+	"azure_app_exporter/logging"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	appmetrics "azure_app_exporter/appMetrics"
+	appsettings "azure_app_exporter/appSettings"
+	globalstate "azure_app_exporter/globalState"
+
+	"github.com/carlmjohnson/requests"
+)
+
+// https://learn.microsoft.com/en-us/graph/throttling#best-practices-to-handle-throttling
+func isThrottled(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable
+}
+
+// Return the delay before the given retry, preferring the Retry-After header (either seconds or an HTTP date)
+// over exponential backoff with jitter. Reports false when Retry-After asks to wait longer than max_backoff
+func retryDelay(retryAfter string, attempt int, policy appsettings.Retry) (time.Duration, bool) {
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+		return cappedDelay(time.Duration(seconds)*time.Second, policy)
+	}
+
+	if date, err := http.ParseTime(retryAfter); err == nil {
+		return cappedDelay(max(time.Until(date), 0), policy)
+	}
+
+	backoff := policy.InitialBackoff.Duration
+	for i := 1; i < attempt && backoff < policy.MaxBackoff.Duration; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, policy.MaxBackoff.Duration)
+	if backoff <= 0 {
+		return 0, true
+	}
+
+	// Spread out retries of concurrent updaters
+	return backoff/2 + rand.N(backoff/2+1), true
+}
+
+func cappedDelay(delay time.Duration, policy appsettings.Retry) (time.Duration, bool) {
+	return delay, delay <= policy.MaxBackoff.Duration
+}
+
+// Return a wrapped http.RoundTripper that retries requests throttled by Azure according to the current [retry] policy
+func RetryTransport(rt http.RoundTripper) requests.Transport {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	return requests.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		endpoint := req.URL.Host + req.URL.Path
+		policy := globalstate.Settings().Retry
+
+		for attempt := 1; ; attempt++ {
+			res, err := rt.RoundTrip(req)
+			if err != nil || !isThrottled(res.StatusCode) {
+				return res, err
+			}
+
+			appmetrics.ThrottledResponses.WithLabelValues(endpoint, strconv.Itoa(res.StatusCode)).Inc()
+
+			// A request whose body can't be replayed can't be retried
+			replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+			if attempt >= policy.MaxAttempts || !replayable {
+				return res, nil
+			}
+
+			delay, ok := retryDelay(res.Header.Get("Retry-After"), attempt, policy)
+			if !ok {
+				logging.With("component", "http_client", "url", endpoint, "status", res.StatusCode).Warnf("not retrying, Retry-After %s exceeds retry.max_backoff %s", delay, policy.MaxBackoff)
+				return res, nil
+			}
+
+			logging.With("component", "http_client", "url", endpoint, "status", res.StatusCode).Warnf("retrying after %s (attempt %d of %d)", delay, attempt+1, policy.MaxAttempts)
+
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+
+			if !globalstate.Sleep(req.Context(), delay) {
+				return nil, req.Context().Err()
+			}
+
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+
+				req = req.Clone(req.Context())
+				req.Body = body
+			}
+
+			appmetrics.RequestRetries.WithLabelValues(endpoint).Inc()
+		}
+	})
+}
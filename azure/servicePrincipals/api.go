@@ -0,0 +1,95 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package serviceprincipals
+
+import (
+	"net/http"
+
+	datatypes "azure_app_exporter/azure/servicePrincipals/dataTypes"
+	fromswaggerui "azure_app_exporter/fromSwaggerUi"
+	globalstate "azure_app_exporter/globalState"
+
+	"github.com/labstack/echo/v4"
+)
+
+// @summary Show all Azure service principals cached in the exporter (truncated in Swagger UI to 50 entries)
+// @description Show all Azure service principals cached in the exporter (truncated in Swagger UI to 50 entries)
+// @description
+// @description Call this endpoint outside Swagger UI to see full response
+// @tags service principals
+// @param tenant_id query string false "Only show service principals of this tenant"
+// @produce json
+// @success 200 {object} map[string]datatypes.AzureServicePrincipal
+// @failure 404 "Unknown tenant"
+// @router /api/service-principals [get]
+func AllServicePrincipals(c echo.Context) error {
+	tenants, ok := globalstate.SelectTenants(c.QueryParam("tenant_id"))
+	if !ok {
+		return c.NoContent(http.StatusNotFound)
+	}
+
+	limit := -1
+	if _, fromUi := c.Request().Header[fromswaggerui.HeaderName]; fromUi {
+		limit = 50
+	}
+
+	servicePrincipals := make(map[string]datatypes.AzureServicePrincipal)
+
+	for _, tenant := range tenants {
+		tenant.ServicePrincipals.RwLock.RLock()
+		for id, servicePrincipal := range tenant.ServicePrincipals.Value {
+			if limit >= 0 && len(servicePrincipals) >= limit {
+				break
+			}
+			servicePrincipals[id] = servicePrincipal
+		}
+		tenant.ServicePrincipals.RwLock.RUnlock()
+	}
+
+	return c.JSON(http.StatusOK, servicePrincipals)
+}
+
+// @summary Show Azure service principal by ID
+// @description Show Azure service principal by ID
+// @tags service principals
+// @param id path string true "ID of Azure service principal to lookup"
+// @param tenant_id query string false "Only lookup the service principal in this tenant"
+// @produce json
+// @success 200 {object} datatypes.AzureServicePrincipal
+// @failure 404 "Unknown service principal or tenant"
+// @router /api/service-principals/{id} [get]
+func ServicePrincipalById(c echo.Context) error {
+	tenants, ok := globalstate.SelectTenants(c.QueryParam("tenant_id"))
+	if !ok {
+		return c.NoContent(http.StatusNotFound)
+	}
+
+	for _, tenant := range tenants {
+		tenant.ServicePrincipals.RwLock.RLock()
+		servicePrincipal, ok := tenant.ServicePrincipals.Value[c.Param("id")]
+		tenant.ServicePrincipals.RwLock.RUnlock()
+
+		if ok {
+			return c.JSON(http.StatusOK, servicePrincipal)
+		}
+	}
+
+	return c.NoContent(http.StatusNotFound)
+}
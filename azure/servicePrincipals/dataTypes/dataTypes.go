@@ -0,0 +1,54 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package datatypes
+
+import (
+	"math"
+	"time"
+
+	appdatatypes "azure_app_exporter/azure/applications/dataTypes"
+)
+
+// https://learn.microsoft.com/en-us/graph/api/resources/serviceprincipal?view=graph-rest-1.0#properties
+type AzureServicePrincipals struct {
+	NextLink *string                 `json:"@odata.nextLink"`
+	Value    []AzureServicePrincipal `json:"value"`
+}
+
+type AzureServicePrincipal struct {
+	Id                                  string                            `json:"id"                                  validate:"required" extensions:"x-order=1"`
+	AppId                               string                            `json:"appId"                               validate:"required" extensions:"x-order=2"`
+	DisplayName                         *string                           `json:"displayName"                                             extensions:"x-order=3,x-nullable"`
+	ServicePrincipalType                *string                           `json:"servicePrincipalType"                                    extensions:"x-order=4,x-nullable" example:"Application"`
+	PreferredSingleSignOnMode           *string                           `json:"preferredSingleSignOnMode"                               extensions:"x-order=5,x-nullable" example:"saml"`
+	PreferredTokenSigningKeyEndDateTime *appdatatypes.UtcTime             `json:"preferredTokenSigningKeyEndDateTime"                     extensions:"x-order=6,x-nullable" swaggertype:"string" format:"date-time"`
+	PasswordCredentials                 []appdatatypes.PasswordCredential `json:"passwordCredentials"                 validate:"required" extensions:"x-order=7"`
+	KeyCredentials                      []appdatatypes.KeyCredential      `json:"keyCredentials"                      validate:"required" extensions:"x-order=8"`
+}
+
+// Return the remaining seconds until the preferred SAML token signing certificate expires
+// If no signing certificate is set, return positive infinity
+func (s AzureServicePrincipal) SamlSigningRemainingSeconds() float64 {
+	if s.PreferredTokenSigningKeyEndDateTime == nil {
+		return math.Inf(1)
+	}
+
+	return time.Until(s.PreferredTokenSigningKeyEndDateTime.Time).Seconds()
+}
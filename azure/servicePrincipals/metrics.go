@@ -0,0 +1,114 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package serviceprincipals
+
+import (
+	"azure_app_exporter/logging"
+
+	appmetrics "azure_app_exporter/appMetrics"
+	appdatatypes "azure_app_exporter/azure/applications/dataTypes"
+	datatypes "azure_app_exporter/azure/servicePrincipals/dataTypes"
+	globalstate "azure_app_exporter/globalState"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Exports the credentials of the cached service principals at scrape time, see the applications collector
+type collector struct{}
+
+func (collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- appmetrics.ServicePrincipalPasswordSeconds
+	ch <- appmetrics.ServicePrincipalCertificateSeconds
+	ch <- appmetrics.ServicePrincipalSamlSigningSeconds
+}
+
+func (collector) Collect(ch chan<- prometheus.Metric) {
+	for _, tenant := range globalstate.Tenants {
+		tenant.ServicePrincipals.RwLock.RLock()
+		servicePrincipals, updatedAt := tenant.ServicePrincipals.Value, tenant.ServicePrincipals.UpdatedAt
+		tenant.ServicePrincipals.RwLock.RUnlock()
+
+		if globalstate.IsStale(updatedAt) {
+			pruned := appmetrics.CountSeries(func(ch chan<- prometheus.Metric) {
+				collectServicePrincipals(ch, tenant.Id, servicePrincipals)
+			})
+			appmetrics.PrunedSeries.WithLabelValues(tenant.Id, "service_principals").Set(float64(pruned))
+			continue
+		}
+
+		appmetrics.PrunedSeries.WithLabelValues(tenant.Id, "service_principals").Set(0)
+		collectServicePrincipals(ch, tenant.Id, servicePrincipals)
+	}
+}
+
+func collectServicePrincipals(ch chan<- prometheus.Metric, tenantId string, servicePrincipals map[string]datatypes.AzureServicePrincipal) {
+	for id, servicePrincipal := range servicePrincipals {
+		for _, password := range servicePrincipal.PasswordCredentials {
+			ch <- prometheus.MustNewConstMetric(
+				appmetrics.ServicePrincipalPasswordSeconds,
+				prometheus.GaugeValue,
+				password.RemainingSeconds(),
+				tenantId,
+				id,
+				servicePrincipal.AppId,
+				appdatatypes.DerefOrDefault(servicePrincipal.DisplayName),
+				password.KeyId,
+				appdatatypes.DerefOrDefault(password.DisplayName),
+				appdatatypes.DerefOrDefaultUtcTime(password.EndDateTime),
+			)
+		}
+
+		for _, certificate := range servicePrincipal.KeyCredentials {
+			ch <- prometheus.MustNewConstMetric(
+				appmetrics.ServicePrincipalCertificateSeconds,
+				prometheus.GaugeValue,
+				certificate.RemainingSeconds(),
+				tenantId,
+				id,
+				servicePrincipal.AppId,
+				appdatatypes.DerefOrDefault(servicePrincipal.DisplayName),
+				certificate.KeyId,
+				appdatatypes.DerefOrDefault(certificate.DisplayName),
+				appdatatypes.DerefOrDefaultUtcTime(certificate.EndDateTime),
+			)
+		}
+
+		// Only service principals configured for SAML SSO have a preferred token signing certificate
+		if servicePrincipal.PreferredTokenSigningKeyEndDateTime != nil {
+			ch <- prometheus.MustNewConstMetric(
+				appmetrics.ServicePrincipalSamlSigningSeconds,
+				prometheus.GaugeValue,
+				servicePrincipal.SamlSigningRemainingSeconds(),
+				tenantId,
+				id,
+				servicePrincipal.AppId,
+				appdatatypes.DerefOrDefault(servicePrincipal.DisplayName),
+				appdatatypes.DerefOrDefaultUtcTime(servicePrincipal.PreferredTokenSigningKeyEndDateTime),
+			)
+		}
+	}
+}
+
+// Register the credential metrics collector, after appmetrics.Init
+func RegisterCollector() {
+	if err := prometheus.Register(collector{}); err != nil {
+		logging.Fatal(err)
+	}
+}
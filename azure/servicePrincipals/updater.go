@@ -0,0 +1,140 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package serviceprincipals
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appmetrics "azure_app_exporter/appMetrics"
+	datatypes "azure_app_exporter/azure/servicePrincipals/dataTypes"
+	globalstate "azure_app_exporter/globalState"
+	"azure_app_exporter/tracing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// https://learn.microsoft.com/en-us/graph/api/serviceprincipal-list?view=graph-rest-1.0
+func AzureServicePrincipalsUpdater(ctx context.Context, tenant *globalstate.Tenant) {
+	log := tenant.Logger("service_principals")
+
+	// This func is spawned in a thread simultaneously with another thread
+	// responsible for updating the api token, so we should wait for it to finish
+	for tenant.AzureApiToken.Value == "" {
+		log.Warn("azure api token not yet acquired, sleeping 5 seconds")
+		if !globalstate.Sleep(ctx, 5*time.Second) {
+			return
+		}
+	}
+
+	httpClient := globalstate.HttpClient.Clone()
+
+	getServicePrincipals := func(ctx context.Context, url string) (datatypes.AzureServicePrincipals, error) {
+		log.With("url", url).Debug("calling with bearer token")
+
+		// Don't hold the lock during the request, retries could block the token updater for a long time
+		tenant.AzureApiToken.RwLock.RLock()
+		token := tenant.AzureApiToken.Value
+		tenant.AzureApiToken.RwLock.RUnlock()
+
+		var response datatypes.AzureServicePrincipals
+		err := httpClient.
+			BaseURL(url).
+			Bearer(token).
+			ToJSON(&response).
+			Fetch(ctx)
+
+		return response, err
+	}
+
+	inner := func(ctx context.Context) error {
+		response, err := getServicePrincipals(
+			ctx,
+			fmt.Sprintf(
+				"%s?$top=%d&$select=id,appId,displayName,servicePrincipalType,preferredSingleSignOnMode,preferredTokenSigningKeyEndDateTime,passwordCredentials,keyCredentials",
+				tenant.Settings().ServicePrincipalsUrl(globalstate.Settings().ServicePrincipals),
+				globalstate.Settings().ServicePrincipals.ResultsPerPage,
+			),
+		)
+		if err != nil {
+			return err
+		}
+
+		for response.NextLink != nil {
+			nextResponse, err := getServicePrincipals(ctx, *response.NextLink)
+			if err != nil {
+				return err
+			}
+
+			response.NextLink = nextResponse.NextLink
+			response.Value = append(response.Value, nextResponse.Value...)
+		}
+
+		servicePrincipals := make(map[string]datatypes.AzureServicePrincipal, len(response.Value))
+		for _, servicePrincipal := range response.Value {
+			servicePrincipals[servicePrincipal.Id] = servicePrincipal
+		}
+
+		tenant.ServicePrincipals.RwLock.Lock()
+		defer tenant.ServicePrincipals.RwLock.Unlock()
+
+		tenant.ServicePrincipals.Value = servicePrincipals
+		tenant.ServicePrincipals.UpdatedAt = time.Now()
+
+		log.Debugf("cached %d service principals", len(servicePrincipals))
+
+		return nil
+	}
+
+	update := func() error {
+		ctx, span := tracing.Tracer().Start(ctx, "update azure service principals", trace.WithAttributes(tracing.TenantKey.String(tenant.Id)))
+		defer span.End()
+
+		err := inner(ctx)
+		tracing.RecordError(span, err)
+
+		return err
+	}
+
+	// Re-read on every call, so a reload changes the interval of the running sleep
+	refreshInterval := func() time.Duration {
+		return tenant.Settings().RefreshInterval(globalstate.Settings().ServicePrincipals.CacheRefreshInterval).Duration
+	}
+
+	for {
+		start := time.Now()
+
+		if err := update(); err == nil {
+			elapsed := time.Since(start)
+			appmetrics.ServicePrincipalsSeconds.WithLabelValues(tenant.Id).Observe(elapsed.Seconds())
+			log.With("latency", elapsed, "next_update", refreshInterval()).Info("updated azure service principals")
+		} else if ctx.Err() != nil {
+			return
+		} else {
+			log.WithError(err).With("next_update", refreshInterval()).Error("failed updating azure service principals")
+			appmetrics.ServicePrincipalsFailures.WithLabelValues(tenant.Id).Inc()
+		}
+
+		if !globalstate.SleepUntil(ctx, func() time.Time { return start.Add(refreshInterval()) }) {
+			return
+		}
+	}
+}
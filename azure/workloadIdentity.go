@@ -0,0 +1,65 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package azure
+
+import (
+	"azure_app_exporter/logging"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	appsettings "azure_app_exporter/appSettings"
+
+	"github.com/carlmjohnson/requests"
+)
+
+// https://learn.microsoft.com/en-us/entra/identity-platform/v2-oauth2-client-creds-grant-flow#third-case-access-token-request-with-a-federated-credential
+// resource is the API the token is for, like the Graph endpoint
+func workloadIdentityToken(ctx context.Context, httpClient *requests.Builder, credentials appsettings.Credentials, resource string) (authToken, error) {
+	tenantId, clientId, tokenFile := credentials.WorkloadIdentity()
+
+	// The authority host honors the AZURE_AUTHORITY_HOST env var unless authority_host is set
+	requestUrl := fmt.Sprintf("%s/%s/oauth2/v2.0/token", credentials.ResolvedAuthorityHost(), tenantId)
+	logging.With("component", "token", "url", requestUrl).Debug("calling with client id and federated token")
+
+	// The kubelet rotates the projected service account token, so it has to be read again on every refresh
+	assertion, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return authToken{}, fmt.Errorf("failed reading federated token file -> %w", err)
+	}
+
+	var response authToken
+	err = httpClient.
+		BaseURL(requestUrl).
+		Post().
+		BodyForm(url.Values{
+			"grant_type":            {"client_credentials"},
+			"scope":                 {resource + "/.default"},
+			"client_id":             {clientId},
+			"client_assertion_type": {clientAssertionType},
+			"client_assertion":      {strings.TrimSpace(string(assertion))},
+		}).
+		ToJSON(&response).
+		Fetch(ctx)
+
+	return response, err
+}
@@ -0,0 +1,133 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"azure_app_exporter/logging"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	appsettings "azure_app_exporter/appSettings"
+	buildinfo "azure_app_exporter/buildInfo"
+)
+
+func usage() {
+	fmt.Fprint(os.Stderr, `Usage: azure_app_exporter [command] [flags]
+
+Commands:
+  serve          Run the exporter, the default when no command is given
+  check-config   Validate the settings and report every error
+  version        Print the version the exporter was built from
+  dump-defaults  Print a settings file with the default value of every setting
+
+Run "azure_app_exporter <command> -h" for the flags of a command.
+`)
+}
+
+// Parse the flags of a command, exiting on -h or an invalid flag
+func parseFlags(command string, args []string, define func(flags *flag.FlagSet)) {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	define(flags)
+
+	if err := flags.Parse(args); err != nil {
+		os.Exit(2)
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected arguments %s\n", strings.Join(flags.Args(), " "))
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+// The flags of the commands reading the settings
+type settingsFlags struct {
+	settingsPath string
+	overrides    appsettings.Overrides
+}
+
+func parseSettingsFlags(command string, args []string) settingsFlags {
+	var (
+		parsed   settingsFlags
+		logLevel string
+	)
+
+	parseFlags(command, args, func(flags *flag.FlagSet) {
+		flags.StringVar(&parsed.settingsPath, "settings", "", "path to the settings file (default $AZURE_APP_EXPORTER_SETTINGS_PATH or /etc/azure_app_exporter/settings.toml)")
+		flags.StringVar(&logLevel, "log-level", "", fmt.Sprintf("minimum level of the logged messages, one of %v, overrides logging.level (default from the settings)", logging.LevelNames()))
+	})
+
+	if logLevel != "" {
+		var level appsettings.LogLevel
+		if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		// Also covers the messages logged while loading the settings
+		logging.SetLevel(logLevel)
+		parsed.overrides.LogLevel = &level
+	}
+	if parsed.settingsPath == "" {
+		parsed.settingsPath = appsettings.SettingsPath()
+	}
+
+	return parsed
+}
+
+// Load the settings for serve, exiting on any error
+func loadSettings(command string, args []string) (appsettings.Settings, appsettings.Overrides) {
+	flags := parseSettingsFlags(command, args)
+
+	settings, err := appsettings.Load(flags.settingsPath)
+	if err != nil {
+		logging.Fatal(err)
+	}
+
+	return settings, flags.overrides
+}
+
+// Report every problem in the settings and return the exit code
+func checkConfig(args []string) int {
+	flags := parseSettingsFlags("check-config", args)
+
+	if _, err := appsettings.Load(flags.settingsPath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Printf("settings in %s are valid\n", flags.settingsPath)
+	return 0
+}
+
+func printVersion(args []string) {
+	parseFlags("version", args, func(*flag.FlagSet) {})
+
+	fmt.Printf("azure_app_exporter %s (commit %s, %s)\n", buildinfo.Version, buildinfo.Commit, runtime.Version())
+}
+
+func dumpDefaults(args []string) {
+	parseFlags("dump-defaults", args, func(*flag.FlagSet) {})
+
+	if err := appsettings.DumpDefaults(os.Stdout); err != nil {
+		logging.Fatal(err)
+	}
+}
@@ -17,7 +17,7 @@ const docTemplate = `{
     "paths": {
         "/api/apps": {
             "get": {
-                "description": "Show all Azure applications cached in the exporter (truncated in Swagger UI to 50 entries)\n\nCall this endpoint outside Swagger UI to see full response",
+                "description": "Show all Azure applications cached in the exporter (truncated in Swagger UI to 50 entries)\n\nCall this endpoint outside Swagger UI to see full response\n\nEvery filter that is set has to match. Credentials are both the passwords and the certificates of an application.",
                 "produces": [
                     "application/json"
                 ],
@@ -25,6 +25,50 @@ const docTemplate = `{
                     "applications"
                 ],
                 "summary": "Show all Azure applications cached in the exporter (truncated in Swagger UI to 50 entries)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only show applications of this tenant",
+                        "name": "tenant_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Case insensitive substring of the display name, or a regular expression enclosed in slashes like /^prod-/",
+                        "name": "display_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only show the application with this client ID",
+                        "name": "app_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only show applications with a credential expiring within this duration, like 30d or 12h",
+                        "name": "expiring_within",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only show applications with (true) or without (false) an expired credential",
+                        "name": "expired",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only show applications with (true) or without (false) any credentials",
+                        "name": "has_credentials",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only show applications with (true) or without (false) a credential that never expires",
+                        "name": "no_end_date",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -34,6 +78,12 @@ const docTemplate = `{
                                 "$ref": "#/definitions/datatypes.AzureApplication"
                             }
                         }
+                    },
+                    "400": {
+                        "description": "Invalid filter"
+                    },
+                    "404": {
+                        "description": "Unknown tenant"
                     }
                 }
             }
@@ -55,6 +105,12 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only lookup the application in this tenant",
+                        "name": "tenant_id",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -63,6 +119,219 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/datatypes.AzureApplication"
                         }
+                    },
+                    "404": {
+                        "description": "Unknown application or tenant"
+                    }
+                }
+            }
+        },
+        "/api/credentials": {
+            "get": {
+                "description": "Show the passwords and certificates of all Azure applications cached in the exporter, one row per credential (truncated in Swagger UI to 50 entries)\n\nCall this endpoint outside Swagger UI to see full response\n\nEvery filter that is set has to match. Credentials without an end date sort as if they expire last.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "credentials"
+                ],
+                "summary": "Show the passwords and certificates of all Azure applications cached in the exporter (truncated in Swagger UI to 50 entries)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only show credentials of this tenant",
+                        "name": "tenant_id",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "password",
+                            "certificate"
+                        ],
+                        "type": "string",
+                        "description": "Only show credentials of this type",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Case insensitive substring of the credential's display name, or a regular expression enclosed in slashes like /^ci-/",
+                        "name": "display_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Case insensitive substring of the application's display name, or a regular expression enclosed in slashes like /^prod-/",
+                        "name": "app_display_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only show credentials of the application with this client ID",
+                        "name": "app_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only show credentials expiring within this duration, like 30d or 12h",
+                        "name": "expiring_within",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only show expired (true) or not yet expired (false) credentials",
+                        "name": "expired",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only show credentials that never expire (true) or that do (false)",
+                        "name": "no_end_date",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "end_date",
+                            "-end_date",
+                            "start_date",
+                            "-start_date",
+                            "display_name",
+                            "-display_name",
+                            "app_display_name",
+                            "-app_display_name"
+                        ],
+                        "type": "string",
+                        "default": "end_date",
+                        "description": "Sort by this column, prefixed with - for descending order",
+                        "name": "sort",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/datatypes.ApplicationCredential"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid filter or sort"
+                    },
+                    "404": {
+                        "description": "Unknown tenant"
+                    }
+                }
+            }
+        },
+        "/api/credentials/{keyId}": {
+            "get": {
+                "description": "Show a password or certificate of an Azure application by key ID, which is what shows up in the audit logs",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "credentials"
+                ],
+                "summary": "Show a password or certificate of an Azure application by key ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Key ID of the credential to lookup",
+                        "name": "keyId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only lookup the credential in this tenant",
+                        "name": "tenant_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/datatypes.ApplicationCredential"
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown credential or tenant"
+                    }
+                }
+            }
+        },
+        "/api/service-principals": {
+            "get": {
+                "description": "Show all Azure service principals cached in the exporter (truncated in Swagger UI to 50 entries)\n\nCall this endpoint outside Swagger UI to see full response",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "service principals"
+                ],
+                "summary": "Show all Azure service principals cached in the exporter (truncated in Swagger UI to 50 entries)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only show service principals of this tenant",
+                        "name": "tenant_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "$ref": "#/definitions/datatypes.AzureServicePrincipal"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown tenant"
+                    }
+                }
+            }
+        },
+        "/api/service-principals/{id}": {
+            "get": {
+                "description": "Show Azure service principal by ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "service principals"
+                ],
+                "summary": "Show Azure service principal by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID of Azure service principal to lookup",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only lookup the service principal in this tenant",
+                        "name": "tenant_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/datatypes.AzureServicePrincipal"
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown service principal or tenant"
                     }
                 }
             }
@@ -86,6 +355,26 @@ const docTemplate = `{
                 }
             }
         },
+        "/healthz": {
+            "get": {
+                "description": "Check whether the exporter process is alive",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Check whether the exporter process is alive",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/health.Status"
+                        }
+                    }
+                }
+            }
+        },
         "/licenses": {
             "get": {
                 "description": "Show licenses\n\nGenerated by go-licenses",
@@ -126,6 +415,32 @@ const docTemplate = `{
                     }
                 }
             }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Check whether the exporter serves fresh data\n\nPasses once every tenant has acquired a token and loaded its applications (and service principals, if enabled),\nand fails again when any of those caches is older than [web] readiness_max_cache_age",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Check whether the exporter serves fresh data",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/health.Status"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/health.Status"
+                        }
+                    }
+                }
+            }
         }
     },
     "definitions": {
@@ -136,37 +451,144 @@ const docTemplate = `{
                     "type": "boolean",
                     "x-order": "1"
                 },
+                "url": {
+                    "type": "string",
+                    "x-order": "2"
+                },
                 "cache_refresh_interval": {
                     "type": "string",
                     "x-order": "2",
                     "example": "15m"
                 },
-                "url": {
-                    "type": "string",
-                    "x-order": "2"
-                },
                 "results_per_page": {
                     "type": "integer",
                     "maximum": 999,
                     "minimum": 1,
                     "x-order": "3"
+                },
+                "sync_mode": {
+                    "type": "string",
+                    "enum": [
+                        "full",
+                        "delta"
+                    ],
+                    "x-order": "4"
+                },
+                "full_resync_interval": {
+                    "type": "string",
+                    "x-order": "5",
+                    "example": "24h"
+                },
+                "cache_file": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "6",
+                    "example": "/var/lib/azure_app_exporter/applications.json"
                 }
             }
         },
         "appsettings.Credentials": {
             "type": "object",
             "properties": {
-                "tenant_id": {
+                "mode": {
                     "type": "string",
+                    "enum": [
+                        "client_credentials",
+                        "managed_identity",
+                        "workload_identity"
+                    ],
                     "x-order": "1"
                 },
-                "client_id": {
+                "tenant_id": {
                     "type": "string",
                     "x-order": "2"
                 },
-                "client_secret": {
+                "client_id": {
                     "type": "string",
                     "x-order": "3"
+                },
+                "client_secret": {
+                    "type": "string",
+                    "x-order": "4"
+                },
+                "client_secret_file": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "5",
+                    "example": "/run/secrets/azure_app_exporter/client_secret"
+                },
+                "client_secret_env": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "6",
+                    "example": "AZURE_CLIENT_SECRET"
+                },
+                "client_secret_provider": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/appsettings.SecretProvider"
+                        }
+                    ],
+                    "x-nullable": true,
+                    "x-order": "7"
+                },
+                "certificate_path": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "8",
+                    "example": "/etc/azure_app_exporter/client.pem"
+                },
+                "key_path": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "9",
+                    "example": "/etc/azure_app_exporter/client.key"
+                },
+                "certificate_password": {
+                    "type": "string",
+                    "x-order": "10"
+                },
+                "certificate_header": {
+                    "type": "string",
+                    "enum": [
+                        "x5t",
+                        "x5c"
+                    ],
+                    "x-order": "11"
+                },
+                "managed_identity_endpoint": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "12",
+                    "example": "http://169.254.169.254/metadata/identity/oauth2/token"
+                },
+                "federated_token_file": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "13",
+                    "example": "/var/run/secrets/azure/tokens/azure-identity-token"
+                },
+                "cloud": {
+                    "type": "string",
+                    "enum": [
+                        "public",
+                        "usgov",
+                        "china",
+                        "custom"
+                    ],
+                    "x-order": "14"
+                },
+                "authority_host": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "15",
+                    "example": "https://login.microsoftonline.com"
+                },
+                "graph_endpoint": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "16",
+                    "example": "https://graph.microsoft.com"
                 }
             }
         },
@@ -178,6 +600,35 @@ const docTemplate = `{
                 }
             }
         },
+        "appsettings.Logging": {
+            "type": "object",
+            "properties": {
+                "level": {
+                    "type": "string",
+                    "enum": [
+                        "debug",
+                        "info",
+                        "warn",
+                        "error",
+                        "off"
+                    ],
+                    "x-order": "1"
+                },
+                "format": {
+                    "type": "string",
+                    "enum": [
+                        "text",
+                        "json"
+                    ],
+                    "x-order": "2"
+                },
+                "output": {
+                    "type": "string",
+                    "x-order": "3",
+                    "example": "stderr"
+                }
+            }
+        },
         "appsettings.Metrics": {
             "type": "object",
             "properties": {
@@ -190,6 +641,14 @@ const docTemplate = `{
                 "expand_unsupported_url_metrics": {
                     "type": "boolean",
                     "x-order": "2"
+                },
+                "layout": {
+                    "type": "string",
+                    "enum": [
+                        "legacy",
+                        "compact"
+                    ],
+                    "x-order": "3"
                 }
             }
         },
@@ -210,11 +669,140 @@ const docTemplate = `{
                 }
             }
         },
+        "appsettings.Reload": {
+            "type": "object",
+            "properties": {
+                "watch_file": {
+                    "type": "boolean",
+                    "x-order": "1"
+                },
+                "watch_interval": {
+                    "type": "string",
+                    "x-order": "2",
+                    "example": "10s"
+                }
+            }
+        },
+        "appsettings.Retry": {
+            "type": "object",
+            "properties": {
+                "max_attempts": {
+                    "type": "integer",
+                    "x-order": "1"
+                },
+                "initial_backoff": {
+                    "type": "string",
+                    "x-order": "2",
+                    "example": "1s"
+                },
+                "max_backoff": {
+                    "type": "string",
+                    "x-order": "3",
+                    "example": "1m"
+                }
+            }
+        },
+        "appsettings.SecretProvider": {
+            "type": "object",
+            "properties": {
+                "type": {
+                    "type": "string",
+                    "enum": [
+                        "azure_key_vault",
+                        "hashicorp_vault"
+                    ],
+                    "x-order": "1",
+                    "example": "azure_key_vault"
+                },
+                "vault_url": {
+                    "description": "Azure Key Vault, authenticated with the managed or workload identity of the host running the exporter",
+                    "type": "string",
+                    "x-order": "2",
+                    "example": "https://my-vault.vault.azure.net"
+                },
+                "secret_name": {
+                    "type": "string",
+                    "x-order": "3",
+                    "example": "azure-app-exporter"
+                },
+                "secret_version": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "4",
+                    "example": "0123456789abcdef0123456789abcdef"
+                },
+                "identity": {
+                    "type": "string",
+                    "enum": [
+                        "managed_identity",
+                        "workload_identity"
+                    ],
+                    "x-order": "5",
+                    "example": "managed_identity"
+                },
+                "identity_client_id": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "6",
+                    "example": "00000000-0000-0000-0000-000000000000"
+                },
+                "address": {
+                    "description": "HashiCorp Vault, reading a KV v1 or v2 secret",
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "7",
+                    "example": "https://vault.example.com:8200"
+                },
+                "path": {
+                    "type": "string",
+                    "x-order": "8",
+                    "example": "secret/data/azure_app_exporter"
+                },
+                "key": {
+                    "type": "string",
+                    "x-order": "9",
+                    "example": "client_secret"
+                },
+                "token_file": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "10",
+                    "example": "/vault/secrets/token"
+                },
+                "namespace": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "11",
+                    "example": "admin"
+                }
+            }
+        },
+        "appsettings.ServicePrincipals": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean",
+                    "x-order": "1"
+                },
+                "cache_refresh_interval": {
+                    "type": "string",
+                    "x-order": "2",
+                    "example": "15m"
+                },
+                "url": {
+                    "type": "string",
+                    "x-order": "3"
+                },
+                "results_per_page": {
+                    "type": "integer",
+                    "maximum": 999,
+                    "minimum": 1,
+                    "x-order": "4"
+                }
+            }
+        },
         "appsettings.Settings": {
             "type": "object",
-            "required": [
-                "credentials"
-            ],
             "properties": {
                 "credentials": {
                     "allOf": [
@@ -224,13 +812,20 @@ const docTemplate = `{
                     ],
                     "x-order": "1"
                 },
+                "tenants": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/appsettings.Tenant"
+                    },
+                    "x-order": "2"
+                },
                 "metrics": {
                     "allOf": [
                         {
                             "$ref": "#/definitions/appsettings.Metrics"
                         }
                     ],
-                    "x-order": "2"
+                    "x-order": "3"
                 },
                 "applications": {
                     "allOf": [
@@ -238,7 +833,31 @@ const docTemplate = `{
                             "$ref": "#/definitions/appsettings.Applications"
                         }
                     ],
-                    "x-order": "3"
+                    "x-order": "4"
+                },
+                "service_principals": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/appsettings.ServicePrincipals"
+                        }
+                    ],
+                    "x-order": "5"
+                },
+                "retry": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/appsettings.Retry"
+                        }
+                    ],
+                    "x-order": "6"
+                },
+                "reload": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/appsettings.Reload"
+                        }
+                    ],
+                    "x-order": "7"
                 },
                 "web": {
                     "allOf": [
@@ -246,7 +865,7 @@ const docTemplate = `{
                             "$ref": "#/definitions/appsettings.Web"
                         }
                     ],
-                    "x-order": "4"
+                    "x-order": "8"
                 },
                 "openapi": {
                     "allOf": [
@@ -254,7 +873,7 @@ const docTemplate = `{
                             "$ref": "#/definitions/appsettings.OpenApi"
                         }
                     ],
-                    "x-order": "5"
+                    "x-order": "9"
                 },
                 "tls": {
                     "allOf": [
@@ -262,7 +881,23 @@ const docTemplate = `{
                             "$ref": "#/definitions/appsettings.Tls"
                         }
                     ],
-                    "x-order": "6"
+                    "x-order": "10"
+                },
+                "logging": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/appsettings.Logging"
+                        }
+                    ],
+                    "x-order": "11"
+                },
+                "tracing": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/appsettings.Tracing"
+                        }
+                    ],
+                    "x-order": "12"
                 },
                 "debug": {
                     "allOf": [
@@ -270,7 +905,29 @@ const docTemplate = `{
                             "$ref": "#/definitions/appsettings.Debug"
                         }
                     ],
-                    "x-order": "7"
+                    "x-order": "13"
+                }
+            }
+        },
+        "appsettings.Tenant": {
+            "type": "object",
+            "required": [
+                "credentials"
+            ],
+            "properties": {
+                "credentials": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/appsettings.Credentials"
+                        }
+                    ],
+                    "x-order": "1"
+                },
+                "cache_refresh_interval": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "2",
+                    "example": "30m"
                 }
             }
         },
@@ -299,6 +956,41 @@ const docTemplate = `{
                 }
             }
         },
+        "appsettings.Tracing": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean",
+                    "x-order": "1"
+                },
+                "protocol": {
+                    "type": "string",
+                    "enum": [
+                        "http",
+                        "grpc"
+                    ],
+                    "x-order": "2"
+                },
+                "endpoint": {
+                    "description": "host:port of the collector, empty uses OTEL_EXPORTER_OTLP_ENDPOINT or the exporter's default",
+                    "type": "string",
+                    "x-order": "3",
+                    "example": "otel-collector:4318"
+                },
+                "insecure": {
+                    "type": "boolean",
+                    "x-order": "4"
+                },
+                "sample_ratio": {
+                    "type": "number",
+                    "x-order": "5"
+                },
+                "service_name": {
+                    "type": "string",
+                    "x-order": "6"
+                }
+            }
+        },
         "appsettings.Web": {
             "type": "object",
             "properties": {
@@ -309,12 +1001,88 @@ const docTemplate = `{
                 "cert_file": {
                     "type": "string",
                     "x-nullable": true,
-                    "x-order": "2"
+                    "x-order": "2",
+                    "example": "/etc/azure_app_exporter/cert.pem"
                 },
                 "key_file": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "3",
+                    "example": "/etc/azure_app_exporter/key.pem"
+                },
+                "readiness_max_cache_age": {
+                    "type": "string",
+                    "x-order": "4",
+                    "example": "1h"
+                },
+                "shutdown_timeout": {
+                    "type": "string",
+                    "x-order": "5",
+                    "example": "30s"
+                }
+            }
+        },
+        "datatypes.ApplicationCredential": {
+            "type": "object",
+            "required": [
+                "appId",
+                "applicationId",
+                "keyId",
+                "tenantId",
+                "type"
+            ],
+            "properties": {
+                "keyId": {
+                    "type": "string",
+                    "x-order": "1"
+                },
+                "type": {
+                    "type": "string",
+                    "enum": [
+                        "password",
+                        "certificate"
+                    ],
+                    "x-order": "2"
+                },
+                "displayName": {
                     "type": "string",
                     "x-nullable": true,
                     "x-order": "3"
+                },
+                "tenantId": {
+                    "type": "string",
+                    "x-order": "4"
+                },
+                "applicationId": {
+                    "type": "string",
+                    "x-order": "5"
+                },
+                "appId": {
+                    "type": "string",
+                    "x-order": "6"
+                },
+                "applicationDisplayName": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "7"
+                },
+                "startDateTime": {
+                    "type": "string",
+                    "format": "date-time",
+                    "x-nullable": true,
+                    "x-order": "8"
+                },
+                "endDateTime": {
+                    "type": "string",
+                    "format": "date-time",
+                    "x-nullable": true,
+                    "x-order": "9"
+                },
+                "remainingSeconds": {
+                    "description": "Negative once expired, null if the credential never expires",
+                    "type": "integer",
+                    "x-nullable": true,
+                    "x-order": "10"
                 }
             }
         },
@@ -323,6 +1091,7 @@ const docTemplate = `{
             "required": [
                 "appId",
                 "id",
+                "keyCredentials",
                 "passwordCredentials"
             ],
             "properties": {
@@ -345,6 +1114,115 @@ const docTemplate = `{
                         "$ref": "#/definitions/datatypes.PasswordCredential"
                     },
                     "x-order": "4"
+                },
+                "keyCredentials": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/datatypes.KeyCredential"
+                    },
+                    "x-order": "5"
+                }
+            }
+        },
+        "datatypes.AzureServicePrincipal": {
+            "type": "object",
+            "required": [
+                "appId",
+                "id",
+                "keyCredentials",
+                "passwordCredentials"
+            ],
+            "properties": {
+                "id": {
+                    "type": "string",
+                    "x-order": "1"
+                },
+                "appId": {
+                    "type": "string",
+                    "x-order": "2"
+                },
+                "displayName": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "3"
+                },
+                "servicePrincipalType": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "4",
+                    "example": "Application"
+                },
+                "preferredSingleSignOnMode": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "5",
+                    "example": "saml"
+                },
+                "preferredTokenSigningKeyEndDateTime": {
+                    "type": "string",
+                    "format": "date-time",
+                    "x-nullable": true,
+                    "x-order": "6"
+                },
+                "passwordCredentials": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/datatypes.PasswordCredential"
+                    },
+                    "x-order": "7"
+                },
+                "keyCredentials": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/datatypes.KeyCredential"
+                    },
+                    "x-order": "8"
+                }
+            }
+        },
+        "datatypes.KeyCredential": {
+            "type": "object",
+            "required": [
+                "keyId"
+            ],
+            "properties": {
+                "keyId": {
+                    "type": "string",
+                    "x-order": "1"
+                },
+                "displayName": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "2"
+                },
+                "type": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "3",
+                    "example": "AsymmetricX509Cert"
+                },
+                "usage": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "4",
+                    "example": "Verify"
+                },
+                "startDateTime": {
+                    "type": "string",
+                    "format": "date-time",
+                    "x-nullable": true,
+                    "x-order": "5"
+                },
+                "endDateTime": {
+                    "type": "string",
+                    "format": "date-time",
+                    "x-nullable": true,
+                    "x-order": "6"
+                },
+                "customKeyIdentifier": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "7"
                 }
             }
         },
@@ -363,11 +1241,51 @@ const docTemplate = `{
                     "x-nullable": true,
                     "x-order": "2"
                 },
+                "hint": {
+                    "type": "string",
+                    "x-nullable": true,
+                    "x-order": "3",
+                    "example": "Zx8"
+                },
+                "startDateTime": {
+                    "type": "string",
+                    "format": "date-time",
+                    "x-nullable": true,
+                    "x-order": "4"
+                },
                 "endDateTime": {
                     "type": "string",
                     "format": "date-time",
                     "x-nullable": true,
-                    "x-order": "3"
+                    "x-order": "5"
+                }
+            }
+        },
+        "health.Status": {
+            "type": "object",
+            "required": [
+                "reasons",
+                "status"
+            ],
+            "properties": {
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "ok",
+                        "unavailable"
+                    ],
+                    "x-order": "1",
+                    "example": "unavailable"
+                },
+                "reasons": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "x-order": "2",
+                    "example": [
+                        "tenant 00000000-0000-0000-0000-000000000000: applications not loaded yet"
+                    ]
                 }
             }
         }
@@ -381,7 +1299,7 @@ var SwaggerInfo = &swag.Spec{
 	BasePath:         "",
 	Schemes:          []string{},
 	Title:            "Azure app exporter",
-	Description:      "Expose Prometheus metrics for expiring Azure password credentials",
+	Description:      "Expose Prometheus metrics for expiring Azure password and certificate credentials",
 	InfoInstanceName: "swagger",
 	SwaggerTemplate:  docTemplate,
 	LeftDelim:        "{{",
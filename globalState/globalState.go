@@ -20,32 +20,172 @@
 package globalstate
 
 import (
+	"context"
 	"crypto/tls"
 	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	appsettings "azure_app_exporter/appSettings"
 	datatypes "azure_app_exporter/azure/applications/dataTypes"
+	spdatatypes "azure_app_exporter/azure/servicePrincipals/dataTypes"
+	"azure_app_exporter/logging"
 
 	"github.com/carlmjohnson/requests"
 )
 
-var (
-	Settings     = appsettings.Parse()
-	HttpClient   = requests.Builder{}
-	Applications = struct {
-		// map of id -> application
-		Value  map[string]datatypes.AzureApplication
-		RwLock sync.RWMutex
-	}{Value: make(map[string]datatypes.AzureApplication)}
+// All the state kept for a single Entra ID tenant
+type Tenant struct {
+	Id            string
+	settings      atomic.Pointer[appsettings.Tenant]
 	AzureApiToken struct {
-		Value  string
-		RwLock sync.RWMutex
+		Value     string
+		ExpiresAt time.Time
+		RwLock    sync.RWMutex
+	}
+	Applications struct {
+		// map of id -> application, replaced as a whole on each refresh and never mutated in place
+		Value     map[string]datatypes.AzureApplication
+		UpdatedAt time.Time
+		// Where to poll the next changes from with [applications] sync_mode = "delta"
+		DeltaLink string
+		// When the applications were last crawled in full
+		FullSyncAt time.Time
+		RwLock     sync.RWMutex
+	}
+	ServicePrincipals struct {
+		// map of id -> service principal, replaced as a whole on each refresh and never mutated in place
+		Value     map[string]spdatatypes.AzureServicePrincipal
+		UpdatedAt time.Time
+		RwLock    sync.RWMutex
+	}
+}
+
+func newTenant(settings appsettings.Tenant) *Tenant {
+	tenant := &Tenant{
+		Id: settings.Credentials.ResolvedTenantId(),
 	}
+	tenant.settings.Store(&settings)
+	tenant.Applications.Value = make(map[string]datatypes.AzureApplication)
+	tenant.ServicePrincipals.Value = make(map[string]spdatatypes.AzureServicePrincipal)
+
+	return tenant
+}
+
+// Return the current settings of the tenant, which may be swapped by a reload at any time
+func (t *Tenant) Settings() appsettings.Tenant {
+	return *t.settings.Load()
+}
+
+// Logger tagged with the given component and the id of the tenant
+func (t *Tenant) Logger(component string) logging.Logger {
+	return logging.With("component", component, "tenant", t.Id)
+}
+
+var (
+	settings   atomic.Pointer[appsettings.Settings]
+	HttpClient = requests.Builder{}
+	// Transport of HttpClient before it gets wrapped with the retry policy in main
+	HttpTransport http.RoundTripper = http.DefaultTransport
+	// In the same order as the [[tenants]] in settings.toml
+	Tenants []*Tenant
+
+	// Closed and replaced on every reload, to wake up everything waiting in SleepUntil
+	reloaded     = make(chan struct{})
+	reloadedLock sync.Mutex
 )
 
-func init() {
-	if Settings.Debug.NoVerifyTls {
-		HttpClient.Transport(&http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}})
+// Return the current settings, which may be swapped by a reload at any time
+func Settings() appsettings.Settings {
+	return *settings.Load()
+}
+
+// Swap in reloaded settings. The tenants must be the same as in the current settings.
+func StoreSettings(newSettings appsettings.Settings) {
+	settings.Store(&newSettings)
+
+	for _, tenant := range Tenants {
+		for _, tenantSettings := range newSettings.Tenants {
+			if tenantSettings.Credentials.ResolvedTenantId() == tenant.Id {
+				tenant.settings.Store(&tenantSettings)
+			}
+		}
+	}
+
+	reloadedLock.Lock()
+	defer reloadedLock.Unlock()
+
+	close(reloaded)
+	reloaded = make(chan struct{})
+}
+
+// Return the tenant with the given ID, or all tenants if the ID is empty
+func SelectTenants(tenantId string) ([]*Tenant, bool) {
+	if tenantId == "" {
+		return Tenants, true
+	}
+
+	for _, tenant := range Tenants {
+		if tenant.Id == tenantId {
+			return []*Tenant{tenant}, true
+		}
+	}
+
+	return nil, false
+}
+
+// Whether a cache last refreshed at updatedAt is older than [metrics] prune_interval, so its metrics are no longer exported
+func IsStale(updatedAt time.Time) bool {
+	pruneInterval := Settings().Metrics.PruneInterval
+	return pruneInterval != nil && !updatedAt.IsZero() && time.Since(updatedAt) >= pruneInterval.Duration
+}
+
+// Sleep for the given duration, returning false early if ctx is cancelled in the meantime
+func Sleep(ctx context.Context, duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Sleep until the deadline returned by the given func, which is evaluated again after every settings reload.
+// Returns false early if ctx is cancelled in the meantime.
+func SleepUntil(ctx context.Context, deadline func() time.Time) bool {
+	for {
+		reloadedLock.Lock()
+		wakeUp := reloaded
+		reloadedLock.Unlock()
+
+		timer := time.NewTimer(time.Until(deadline()))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+			return true
+		case <-wakeUp:
+			timer.Stop()
+		}
+	}
+}
+
+// Store the settings loaded at startup and set up the tenants, before anything reads the settings
+func Init(initialSettings appsettings.Settings) {
+	settings.Store(&initialSettings)
+
+	if Settings().Debug.NoVerifyTls {
+		HttpTransport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+		HttpClient.Transport(HttpTransport)
+	}
+
+	for _, tenant := range Settings().Tenants {
+		Tenants = append(Tenants, newTenant(tenant))
 	}
 }
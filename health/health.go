@@ -0,0 +1,118 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	globalstate "azure_app_exporter/globalState"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Status struct {
+	Status  string   `json:"status"  validate:"required" example:"unavailable" enums:"ok,unavailable" extensions:"x-order=1"`
+	Reasons []string `json:"reasons" validate:"required" example:"tenant 00000000-0000-0000-0000-000000000000: applications not loaded yet" extensions:"x-order=2"`
+}
+
+// @summary Check whether the exporter process is alive
+// @description Check whether the exporter process is alive
+// @tags health
+// @produce json
+// @success 200 {object} health.Status
+// @router /healthz [get]
+func Healthz(c echo.Context) error {
+	return c.JSON(http.StatusOK, Status{Status: "ok", Reasons: []string{}})
+}
+
+// @summary Check whether the exporter serves fresh data
+// @description Check whether the exporter serves fresh data
+// @description
+// @description Passes once every tenant has acquired a token and loaded its applications (and service principals, if enabled),
+// @description and fails again when any of those caches is older than [web] readiness_max_cache_age
+// @tags health
+// @produce json
+// @success 200 {object} health.Status
+// @failure 503 {object} health.Status
+// @router /readyz [get]
+func Readyz(c echo.Context) error {
+	reasons := NotReadyReasons()
+	if len(reasons) > 0 {
+		return c.JSON(http.StatusServiceUnavailable, Status{Status: "unavailable", Reasons: reasons})
+	}
+
+	return c.JSON(http.StatusOK, Status{Status: "ok", Reasons: reasons})
+}
+
+// Return why the exporter should not receive traffic yet, or an empty slice if it's ready
+func NotReadyReasons() []string {
+	reasons := []string{}
+	maxCacheAge := globalstate.Settings().Web.ReadinessMaxCacheAge.Duration
+
+	for _, tenant := range globalstate.Tenants {
+		if globalstate.Settings().Applications.Enabled || globalstate.Settings().ServicePrincipals.Enabled {
+			tenant.AzureApiToken.RwLock.RLock()
+			token, expiresAt := tenant.AzureApiToken.Value, tenant.AzureApiToken.ExpiresAt
+			tenant.AzureApiToken.RwLock.RUnlock()
+
+			if token == "" {
+				reasons = append(reasons, fmt.Sprintf("tenant %s: api token not acquired yet", tenant.Id))
+			} else if time.Now().After(expiresAt) {
+				reasons = append(reasons, fmt.Sprintf("tenant %s: api token expired at %s", tenant.Id, expiresAt.UTC().Format(time.RFC3339)))
+			}
+		}
+
+		if globalstate.Settings().Applications.Enabled {
+			tenant.Applications.RwLock.RLock()
+			updatedAt := tenant.Applications.UpdatedAt
+			tenant.Applications.RwLock.RUnlock()
+
+			if reason := cacheReason(updatedAt, maxCacheAge); reason != "" {
+				reasons = append(reasons, fmt.Sprintf("tenant %s: applications %s", tenant.Id, reason))
+			}
+		}
+
+		if globalstate.Settings().ServicePrincipals.Enabled {
+			tenant.ServicePrincipals.RwLock.RLock()
+			updatedAt := tenant.ServicePrincipals.UpdatedAt
+			tenant.ServicePrincipals.RwLock.RUnlock()
+
+			if reason := cacheReason(updatedAt, maxCacheAge); reason != "" {
+				reasons = append(reasons, fmt.Sprintf("tenant %s: service principals %s", tenant.Id, reason))
+			}
+		}
+	}
+
+	return reasons
+}
+
+func cacheReason(updatedAt time.Time, maxCacheAge time.Duration) string {
+	if updatedAt.IsZero() {
+		return "not loaded yet"
+	}
+
+	if age := time.Since(updatedAt); age > maxCacheAge {
+		return fmt.Sprintf("last refreshed %s ago, more than the allowed %s", age.Truncate(time.Second), maxCacheAge)
+	}
+
+	return ""
+}
@@ -2,6 +2,10 @@
 
 set -eu
 
+VERSION=$(git describe --tags --always --dirty 2>/dev/null || echo dev)
+COMMIT=$(git rev-parse HEAD 2>/dev/null || echo unknown)
+
 docker build -t go-builder .
 
-docker run -it --rm -v ./:/src go-builder go build
+docker run -it --rm -v ./:/src go-builder go build \
+    -ldflags "-X azure_app_exporter/buildInfo.Version=${VERSION} -X azure_app_exporter/buildInfo.Commit=${COMMIT}"
@@ -20,27 +20,277 @@
 package logging
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
 
-	"github.com/labstack/gommon/log"
+const (
+	// Logged right before exiting, above slog.LevelError
+	LevelFatal = slog.Level(12)
+	// Above every level, disables logging
+	LevelOff = slog.Level(16)
 )
 
-func init() {
-	// Format specifiers https://echo.labstack.com/docs/customization#log-header
-	log.SetHeader("${time_rfc3339} ${level} ${short_file}:${line}")
-	log.SetLevel(log.DEBUG)
-	log.SetOutput(os.Stderr)
+var levels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+	"off":   LevelOff,
+}
+
+// The level names accepted by ParseLevel
+func LevelNames() []string {
+	names := make([]string, 0, len(levels))
+	for name := range levels {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+func ParseLevel(name string) (slog.Level, error) {
+	level, ok := levels[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("invalid log level %s, expected one of %v", name, LevelNames())
+	}
+
+	return level, nil
+}
+
+// How and where log records are written, see [logging] in the settings
+type Options struct {
+	Level slog.Level
+	Json  bool
+	// "stderr", "stdout" or the path of a file to append to
+	Output string
+}
+
+// A configured log destination, built by NewOutput and activated by SetOutput
+type Output struct {
+	level   slog.Level
+	handler slog.Handler
+	file    *os.File
+	// The path file was opened from
+	path string
 }
 
 var (
-	Debug  = log.Debug
-	Debugf = log.Debugf
-	Info   = log.Info
-	Infof  = log.Infof
-	Warn   = log.Warn
-	Warnf  = log.Warnf
-	Error  = log.Error
-	Errorf = log.Errorf
-	Fatal  = log.Fatal
-	Fatalf = log.Fatalf
+	level   = new(slog.LevelVar)
+	current atomic.Pointer[Output]
+
+	// Every logger derives from this one, and follows SetOutput
+	root = Logger{slog.New(forwardHandler{})}
 )
+
+func init() {
+	current.Store(&Output{level: slog.LevelInfo, handler: newHandler(os.Stderr, false)})
+}
+
+func newHandler(w io.Writer, json bool) slog.Handler {
+	options := &slog.HandlerOptions{
+		AddSource: true,
+		Level:     level,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			switch {
+			case len(groups) > 0:
+			case attr.Key == slog.SourceKey:
+				if source, ok := attr.Value.Any().(*slog.Source); ok {
+					attr.Value = slog.StringValue(fmt.Sprintf("%s:%d", filepath.Base(source.File), source.Line))
+				}
+			case attr.Key == slog.LevelKey && attr.Value.Any() == LevelFatal:
+				attr.Value = slog.StringValue("FATAL")
+			case attr.Value.Kind() == slog.KindDuration:
+				// "1.5s" rather than nanoseconds, in both formats
+				attr.Value = slog.StringValue(attr.Value.Duration().String())
+			}
+			return attr
+		},
+	}
+
+	if json {
+		return slog.NewJSONHandler(w, options)
+	}
+	return slog.NewTextHandler(w, options)
+}
+
+// Open the output described by options, without activating it yet
+func NewOutput(options Options) (*Output, error) {
+	output := &Output{level: options.Level}
+
+	var w io.Writer
+	switch options.Output {
+	case "", "stderr":
+		w = os.Stderr
+	case "stdout":
+		w = os.Stdout
+	default:
+		// Keep writing to the file that's already open, closing it could lose records still being written
+		if previous := current.Load(); previous.file != nil && previous.path == options.Output {
+			output.file, output.path, w = previous.file, previous.path, previous.file
+			break
+		}
+
+		file, err := os.OpenFile(options.Output, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed opening log output -> %w", err)
+		}
+		output.file, output.path, w = file, options.Output, file
+	}
+
+	output.handler = newHandler(w, options.Json)
+
+	return output, nil
+}
+
+// Route every logger to output, closing the file of the previous one unless output reuses it
+func SetOutput(output *Output) {
+	level.Set(output.level)
+
+	if previous := current.Swap(output); previous.file != nil && previous.file != output.file {
+		previous.file.Close()
+	}
+}
+
+// Open and activate the output described by options
+func Configure(options Options) error {
+	output, err := NewOutput(options)
+	if err != nil {
+		return err
+	}
+
+	SetOutput(output)
+	return nil
+}
+
+// Only log records of this level or above, until the next SetOutput
+func SetLevel(name string) error {
+	parsed, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+
+	level.Set(parsed)
+	return nil
+}
+
+// Forwards to the current output, so loggers derived before SetOutput follow it. Groups are not used.
+type forwardHandler struct {
+	attrs []slog.Attr
+}
+
+func (h forwardHandler) Enabled(_ context.Context, recordLevel slog.Level) bool {
+	return recordLevel >= level.Level()
+}
+
+func (h forwardHandler) Handle(ctx context.Context, record slog.Record) error {
+	handler := current.Load().handler
+	if len(h.attrs) > 0 {
+		handler = handler.WithAttrs(h.attrs)
+	}
+
+	return handler.Handle(ctx, record)
+}
+
+func (h forwardHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return forwardHandler{attrs: append(slices.Clip(h.attrs), attrs...)}
+}
+
+func (h forwardHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// A logger adding structured fields to every record, see With. The usual fields are
+// component, tenant, url, status, latency and error.
+type Logger struct {
+	logger *slog.Logger
+}
+
+// Return a logger adding the given key-value pairs to every record
+func With(args ...any) Logger {
+	return root.With(args...)
+}
+
+func (l Logger) With(args ...any) Logger {
+	return Logger{l.logger.With(args...)}
+}
+
+// Add the error field, if err is not nil
+func (l Logger) WithError(err error) Logger {
+	if err == nil {
+		return l
+	}
+
+	return l.With("error", err.Error())
+}
+
+func (l Logger) log(recordLevel slog.Level, message string) {
+	if !l.logger.Enabled(context.Background(), recordLevel) {
+		return
+	}
+
+	// Skip runtime.Callers, log and the exported method, so the source is the caller's
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+
+	record := slog.NewRecord(time.Now(), recordLevel, message, pcs[0])
+	l.logger.Handler().Handle(context.Background(), record)
+}
+
+func (l Logger) Debug(args ...any) { l.log(slog.LevelDebug, fmt.Sprint(args...)) }
+func (l Logger) Info(args ...any)  { l.log(slog.LevelInfo, fmt.Sprint(args...)) }
+func (l Logger) Warn(args ...any)  { l.log(slog.LevelWarn, fmt.Sprint(args...)) }
+func (l Logger) Error(args ...any) { l.log(slog.LevelError, fmt.Sprint(args...)) }
+
+func (l Logger) Debugf(format string, args ...any) {
+	l.log(slog.LevelDebug, fmt.Sprintf(format, args...))
+}
+func (l Logger) Infof(format string, args ...any) {
+	l.log(slog.LevelInfo, fmt.Sprintf(format, args...))
+}
+func (l Logger) Warnf(format string, args ...any) {
+	l.log(slog.LevelWarn, fmt.Sprintf(format, args...))
+}
+func (l Logger) Errorf(format string, args ...any) {
+	l.log(slog.LevelError, fmt.Sprintf(format, args...))
+}
+
+func (l Logger) Fatal(args ...any) {
+	l.log(LevelFatal, fmt.Sprint(args...))
+	os.Exit(1)
+}
+
+func (l Logger) Fatalf(format string, args ...any) {
+	l.log(LevelFatal, fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
+
+func Debug(args ...any) { root.log(slog.LevelDebug, fmt.Sprint(args...)) }
+func Info(args ...any)  { root.log(slog.LevelInfo, fmt.Sprint(args...)) }
+func Warn(args ...any)  { root.log(slog.LevelWarn, fmt.Sprint(args...)) }
+func Error(args ...any) { root.log(slog.LevelError, fmt.Sprint(args...)) }
+
+func Debugf(format string, args ...any) { root.log(slog.LevelDebug, fmt.Sprintf(format, args...)) }
+func Infof(format string, args ...any)  { root.log(slog.LevelInfo, fmt.Sprintf(format, args...)) }
+func Warnf(format string, args ...any)  { root.log(slog.LevelWarn, fmt.Sprintf(format, args...)) }
+func Errorf(format string, args ...any) { root.log(slog.LevelError, fmt.Sprintf(format, args...)) }
+
+func Fatal(args ...any) {
+	root.log(LevelFatal, fmt.Sprint(args...))
+	os.Exit(1)
+}
+
+func Fatalf(format string, args ...any) {
+	root.log(LevelFatal, fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
@@ -22,13 +22,26 @@ package main
 import (
 	"azure_app_exporter/azure"
 	"azure_app_exporter/azure/applications"
+	serviceprincipals "azure_app_exporter/azure/servicePrincipals"
+	"azure_app_exporter/health"
 	"azure_app_exporter/logging"
 	"azure_app_exporter/pages"
+	"azure_app_exporter/reload"
+	"azure_app_exporter/tracing"
+	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 
+	appmetrics "azure_app_exporter/appMetrics"
+	appsettings "azure_app_exporter/appSettings"
 	apisettings "azure_app_exporter/appSettings/api"
 
 	_ "azure_app_exporter/docs"
@@ -42,26 +55,116 @@ import (
 	echoSwagger "github.com/swaggo/echo-swagger"
 )
 
+// The TLS config of the server, rebuilt from the settings on every reload
+var serverTlsConfig atomic.Pointer[tls.Config]
+
+func newServerTlsConfig(settings appsettings.Settings) (*tls.Config, error) {
+	certificate, err := tls.LoadX509KeyPair(*settings.Web.CertFile, *settings.Web.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		CipherSuites: settings.Tls.ToCipherSuites(),
+		MinVersion:   uint16(settings.Tls.ProtocolVersions[0]),
+		MaxVersion:   uint16(settings.Tls.ProtocolVersions[len(settings.Tls.ProtocolVersions)-1]),
+	}, nil
+}
+
 // @title Azure app exporter
 // @version 0.1.0
 // TODO choose license
-// @description Expose Prometheus metrics for expiring Azure password credentials
+// @description Expose Prometheus metrics for expiring Azure password and certificate credentials
 func main() {
-	if globalstate.Settings.Debug.NoVerifyTls {
-		logging.Warn("flag no_verify_tls is enabled, CERTIFICATES ON FOREIGN API REQUESTS WILL NOT BE VALIDATED!")
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		serve(loadSettings(command, args))
+	case "check-config":
+		os.Exit(checkConfig(args))
+	case "version":
+		printVersion(args)
+	case "dump-defaults":
+		dumpDefaults(args)
+	case "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", command)
+		usage()
+		os.Exit(2)
+	}
+}
+
+// Run the exporter until SIGINT or SIGTERM
+func serve(settings appsettings.Settings, overrides appsettings.Overrides) {
+	log := logging.With("component", "server")
+	accessLog := logging.With("component", "http")
+
+	overrides.Apply(&settings)
+	reload.SetOverrides(overrides)
+
+	if err := logging.Configure(settings.Logging.Options()); err != nil {
+		log.Fatal(err)
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), settings.Tracing)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	globalstate.Init(settings)
+	appmetrics.Init()
+	applications.RegisterCollector()
+	serviceprincipals.RegisterCollector()
+
+	if globalstate.Settings().Debug.NoVerifyTls {
+		log.Warn("flag no_verify_tls is enabled, CERTIFICATES ON FOREIGN API REQUESTS WILL NOT BE VALIDATED!")
 	}
 
 	e := echo.New()
 	e.HideBanner = true
+	e.HidePort = true
 
 	e.Use(
-		middleware.Recover(),
-		middleware.LoggerWithConfig(
-			middleware.LoggerConfig{
-				// This logger does not support ${level}
-				// Format specifiers https://pkg.go.dev/github.com/labstack/echo/v4@v4.11.2/middleware#LoggerConfig
-				Format: "${time_rfc3339} request{method=${method} uri=${host}${uri} version=${protocol}}: latency=${latency_human} status=${status}\n",
-				Output: os.Stderr,
+		middleware.RecoverWithConfig(middleware.RecoverConfig{
+			LogErrorFunc: func(c echo.Context, err error, stack []byte) error {
+				accessLog.WithError(err).With("stack", string(stack)).Error("recovered from a panic")
+				return err
+			},
+		}),
+		// Outside of the request logger, so the access log lines are written within the request span
+		tracing.Middleware("/metrics", "/healthz", "/readyz"),
+		middleware.RequestLoggerWithConfig(
+			middleware.RequestLoggerConfig{
+				LogMethod:   true,
+				LogHost:     true,
+				LogURI:      true,
+				LogProtocol: true,
+				LogStatus:   true,
+				LogLatency:  true,
+				LogError:    true,
+				HandleError: true, // Let the error handler pick the status before it gets logged
+				LogValuesFunc: func(c echo.Context, v middleware.RequestLoggerValues) error {
+					requestLog := accessLog.With(
+						"method", v.Method,
+						"url", v.Host+v.URI,
+						"protocol", v.Protocol,
+						"status", v.Status,
+						"latency", v.Latency,
+					).WithError(v.Error)
+
+					if v.Status >= http.StatusInternalServerError {
+						requestLog.Error("request")
+					} else {
+						requestLog.Info("request")
+					}
+					return nil
+				},
 			},
 		),
 		echoprometheus.NewMiddlewareWithConfig(echoprometheus.MiddlewareConfig{
@@ -70,7 +173,7 @@ func main() {
 				"url": func(c echo.Context, err error) string { // Replace the default "url" label
 					if c.Path() != "" { // The current path is a registered endpoint
 						return c.Path()
-					} else if globalstate.Settings.Metrics.ExpandUnsupportedUrlMetrics {
+					} else if globalstate.Settings().Metrics.ExpandUnsupportedUrlMetrics {
 						return c.Request().URL.String()
 					}
 					return "unsupported-url"
@@ -106,39 +209,142 @@ func main() {
 		fromswaggerui.SetSwaggerUiHeader,
 	)
 
-	if globalstate.Settings.Applications.Enabled {
-		go azure.AzureApiTokenUpdater()
-		go applications.AzureApplicationsUpdater()
+	globalstate.HttpClient.Transport(azure.RetryTransport(tracing.Transport(globalstate.HttpTransport)))
+
+	// Cancelled on SIGINT or SIGTERM, which stops the updaters and drains the server
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var updaters sync.WaitGroup
+	spawn := func(updater func(context.Context, *globalstate.Tenant), tenant *globalstate.Tenant) {
+		updaters.Add(1)
+		go func() {
+			defer updaters.Done()
+			updater(ctx, tenant)
+		}()
+	}
+
+	if globalstate.Settings().Applications.Enabled {
+		applications.LoadCacheFile()
+	}
+
+	// Reject a reload whose certificate can't be loaded, the server keeps the current one
+	reload.AddHook(func(next appsettings.Settings) (func(), error) {
+		if serverTlsConfig.Load() == nil || next.Web.CertFile == nil || next.Web.KeyFile == nil {
+			return func() {}, nil
+		}
+
+		tlsConfig, err := newServerTlsConfig(next)
+		if err != nil {
+			return nil, err
+		}
+
+		return func() { serverTlsConfig.Store(tlsConfig) }, nil
+	})
+
+	// A broken log file rejects the reload, the current output stays in place
+	reload.AddHook(func(next appsettings.Settings) (func(), error) {
+		output, err := logging.NewOutput(next.Logging.Options())
+		if err != nil {
+			return nil, err
+		}
+
+		return func() { logging.SetOutput(output) }, nil
+	})
+
+	go reload.Run(ctx)
+
+	for _, tenant := range globalstate.Tenants {
+		if globalstate.Settings().Applications.Enabled || globalstate.Settings().ServicePrincipals.Enabled {
+			spawn(azure.AzureApiTokenUpdater, tenant)
+		}
+
+		if globalstate.Settings().Applications.Enabled {
+			spawn(applications.AzureApplicationsUpdater, tenant)
+		}
+
+		if globalstate.Settings().ServicePrincipals.Enabled {
+			spawn(serviceprincipals.AzureServicePrincipalsUpdater, tenant)
+		}
 	}
 
-	if globalstate.Settings.OpenApi.Enabled {
-		e.GET(globalstate.Settings.OpenApi.SwaggerUiUrl+"/*", echoSwagger.WrapHandler)
+	if globalstate.Settings().OpenApi.Enabled {
+		e.GET(globalstate.Settings().OpenApi.SwaggerUiUrl+"/*", echoSwagger.WrapHandler)
 	}
 
+	e.GET("/healthz", health.Healthz)
+	e.GET("/readyz", health.Readyz)
 	e.GET("/licenses", pages.Licenses)
 	e.GET("/metrics", pages.Metrics)
 	e.GET("/api/settings", apisettings.ApiSettings)
 	e.GET("/api/apps", applications.AllApplications)
 	e.GET("/api/apps/:id", applications.ApplicationById)
+	e.GET("/api/credentials", applications.AllCredentials)
+	e.GET("/api/credentials/:keyId", applications.CredentialByKeyId)
+	e.GET("/api/service-principals", serviceprincipals.AllServicePrincipals)
+	e.GET("/api/service-principals/:id", serviceprincipals.ServicePrincipalById)
 
-	logging.Infof("beginning to serve on %s", globalstate.Settings.Web.ListenAddress)
-	logging.Infof("metrics endpoint: %s", globalstate.Settings.Web.ListenAddress+"/metrics")
-	logging.Infof("swagger endpoint: %s", globalstate.Settings.Web.ListenAddress+globalstate.Settings.OpenApi.SwaggerUiUrl+"/index.html")
-
-	if globalstate.Settings.Web.CertFile != nil && globalstate.Settings.Web.KeyFile != nil {
-		server := http.Server{
-			Addr:    globalstate.Settings.Web.ListenAddress,
-			Handler: e,
-			TLSConfig: &tls.Config{
-				CipherSuites: globalstate.Settings.Tls.ToCipherSuites(),
-				MinVersion:   uint16(globalstate.Settings.Tls.ProtocolVersions[0]),
-				MaxVersion:   uint16(globalstate.Settings.Tls.ProtocolVersions[len(globalstate.Settings.Tls.ProtocolVersions)-1]),
-			},
+	log.Infof("beginning to serve on %s", globalstate.Settings().Web.ListenAddress)
+	log.Infof("metrics endpoint: %s", globalstate.Settings().Web.ListenAddress+"/metrics")
+	log.Infof("swagger endpoint: %s", globalstate.Settings().Web.ListenAddress+globalstate.Settings().OpenApi.SwaggerUiUrl+"/index.html")
+
+	go func() {
+		var err error
+
+		if settings := globalstate.Settings(); settings.Web.CertFile != nil && settings.Web.KeyFile != nil {
+			tlsConfig, tlsErr := newServerTlsConfig(settings)
+			if tlsErr != nil {
+				log.Fatal(tlsErr)
+			}
+			serverTlsConfig.Store(tlsConfig)
+
+			// Serve through e.TLSServer so e.Shutdown drains it. Every handshake picks up the current
+			// certificate and TLS settings, which are swapped on reload.
+			e.TLSServer.Addr = settings.Web.ListenAddress
+			e.TLSServer.TLSConfig = &tls.Config{
+				GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+					return serverTlsConfig.Load(), nil
+				},
+			}
+
+			err = e.StartServer(e.TLSServer)
+		} else {
+			log.Warn("no cert or key file provided in settings.toml, running server in HTTP mode")
+			err = e.Start(globalstate.Settings().Web.ListenAddress)
 		}
 
-		e.Logger.Fatal(server.ListenAndServeTLS(*globalstate.Settings.Web.CertFile, *globalstate.Settings.Web.KeyFile))
-	} else {
-		logging.Warn("no cert or key file provided in settings.toml, running server in HTTP mode")
-		e.Logger.Fatal(e.Start(globalstate.Settings.Web.ListenAddress))
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+
+	shutdownTimeout := globalstate.Settings().Web.ShutdownTimeout.Duration
+	log.Infof("shutting down, waiting up to %s for in-flight requests to finish", shutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		log.WithError(err).Error("failed shutting down the server gracefully")
+	}
+
+	updatersDone := make(chan struct{})
+	go func() {
+		updaters.Wait()
+		close(updatersDone)
+	}()
+
+	select {
+	case <-updatersDone:
+		log.Info("shut down gracefully")
+	case <-shutdownCtx.Done():
+		log.Warn("timed out waiting for the updaters to stop")
+	}
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.WithError(err).Warn("failed flushing the pending spans")
 	}
 }
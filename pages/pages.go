@@ -20,8 +20,6 @@
 package pages
 
 import (
-	"azure_app_exporter/azure/applications"
-	"bytes"
 	_ "embed"
 	"net/http"
 
@@ -29,7 +27,7 @@ import (
 
 	"github.com/labstack/echo/v4"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 //go:embed licenses.csv
@@ -44,19 +42,39 @@ var licenses string
 // @success 200 {object} string
 // @router /metrics [get]
 func Metrics(c echo.Context) error {
-	applications.UpdateApplicationsMetrics()
+	// Through echo's response, so the status and size reach the access log, the span and the metrics middleware
+	var response http.ResponseWriter = c.Response()
+	request := c.Request()
 
-	metrics, _ := prometheus.DefaultGatherer.Gather()
-	var buffer bytes.Buffer
-	for _, metric := range metrics {
-		expfmt.MetricFamilyToText(&buffer, metric)
+	if _, fromUi := request.Header[fromswaggerui.HeaderName]; fromUi {
+		// A compressed body can't be truncated
+		request.Header.Del(echo.HeaderAcceptEncoding)
+		response = &truncatingWriter{Response: c.Response(), remaining: 1024 * 20}
 	}
 
-	if _, fromUi := c.Request().Header[fromswaggerui.HeaderName]; fromUi {
-		buffer.Truncate(min(buffer.Len(), 1024*20))
+	metricsHandler.ServeHTTP(response, request)
+
+	return nil
+}
+
+var metricsHandler = promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{})
+
+// Silently drops everything written past the first `remaining` bytes
+type truncatingWriter struct {
+	*echo.Response
+	remaining int
+}
+
+func (w *truncatingWriter) Write(p []byte) (int, error) {
+	n := min(len(p), w.remaining)
+	if n > 0 {
+		if _, err := w.Response.Write(p[:n]); err != nil {
+			return 0, err
+		}
+		w.remaining -= n
 	}
 
-	return c.Blob(http.StatusOK, "text/plain", buffer.Bytes())
+	return len(p), nil
 }
 
 // @summary Show licenses
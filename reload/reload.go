@@ -0,0 +1,213 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package reload
+
+import (
+	"azure_app_exporter/logging"
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	appmetrics "azure_app_exporter/appMetrics"
+	appsettings "azure_app_exporter/appSettings"
+	globalstate "azure_app_exporter/globalState"
+)
+
+// Called with validated settings before they are swapped in. Returning an error rejects the whole reload,
+// otherwise apply is called once the new settings are in place.
+type Hook func(next appsettings.Settings) (apply func(), err error)
+
+var (
+	hooks []Hook
+	// Command line overrides applied to every reloaded file
+	overrides appsettings.Overrides
+	log       = logging.With("component", "reload")
+
+	// Serializes reloads triggered by SIGHUP and by the file watcher
+	reloadLock sync.Mutex
+)
+
+// Apply these command line overrides to every reloaded file
+func SetOverrides(o appsettings.Overrides) {
+	overrides = o
+}
+
+// Register a hook run on every reload
+func AddHook(hook Hook) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	hooks = append(hooks, hook)
+}
+
+// Settings which are only read at startup, a changed value is ignored until the next restart
+func keepRestartOnly(current appsettings.Settings, next *appsettings.Settings) []string {
+	var kept []string
+
+	keep := func(name string, changed bool, restore func()) {
+		if changed {
+			kept = append(kept, name)
+			restore()
+		}
+	}
+
+	keep("metrics.layout", current.Metrics.Layout != next.Metrics.Layout, func() { next.Metrics.Layout = current.Metrics.Layout })
+	keep("applications.enabled", current.Applications.Enabled != next.Applications.Enabled, func() { next.Applications.Enabled = current.Applications.Enabled })
+	keep("applications.sync_mode", current.Applications.SyncMode != next.Applications.SyncMode, func() { next.Applications.SyncMode = current.Applications.SyncMode })
+	keep("service_principals.enabled", current.ServicePrincipals.Enabled != next.ServicePrincipals.Enabled, func() { next.ServicePrincipals.Enabled = current.ServicePrincipals.Enabled })
+	keep("web.listen_address", current.Web.ListenAddress != next.Web.ListenAddress, func() { next.Web.ListenAddress = current.Web.ListenAddress })
+	keep("openapi", current.OpenApi != next.OpenApi, func() { next.OpenApi = current.OpenApi })
+	keep("tracing", current.Tracing != next.Tracing, func() { next.Tracing = current.Tracing })
+	keep("debug.no_verify_tls", current.Debug.NoVerifyTls != next.Debug.NoVerifyTls, func() { next.Debug.NoVerifyTls = current.Debug.NoVerifyTls })
+
+	// Switching between HTTP and HTTPS needs a new listener, changing the cert and key files does not
+	currentTls := current.Web.CertFile != nil && current.Web.KeyFile != nil
+	nextTls := next.Web.CertFile != nil && next.Web.KeyFile != nil
+	keep("web.cert_file and web.key_file", currentTls != nextTls, func() {
+		next.Web.CertFile = current.Web.CertFile
+		next.Web.KeyFile = current.Web.KeyFile
+	})
+
+	return kept
+}
+
+func tenantIds(settings appsettings.Settings) []string {
+	ids := make([]string, 0, len(settings.Tenants))
+	for _, tenant := range settings.Tenants {
+		ids = append(ids, tenant.Credentials.ResolvedTenantId())
+	}
+
+	return ids
+}
+
+// Load, validate and swap in the settings file, keeping the current settings on any error
+func Reload() error {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	err := reload()
+	if err != nil {
+		appmetrics.ConfigReloads.WithLabelValues("failure").Inc()
+		return err
+	}
+
+	appmetrics.ConfigReloads.WithLabelValues("success").Inc()
+	return nil
+}
+
+func reload() error {
+	next, err := appsettings.Load(globalstate.Settings().Path)
+	if err != nil {
+		return err
+	}
+	overrides.Apply(&next)
+
+	current := globalstate.Settings()
+
+	// The updaters and metrics are keyed by tenant, adding or removing one needs a restart
+	if !slices.Equal(tenantIds(current), tenantIds(next)) {
+		return errors.New("the tenants changed, which requires a restart")
+	}
+
+	for _, name := range keepRestartOnly(current, &next) {
+		log.Warnf("settings value %s changed, this only takes effect after a restart", name)
+	}
+
+	applies := make([]func(), 0, len(hooks))
+	for _, hook := range hooks {
+		apply, err := hook(next)
+		if err != nil {
+			return err
+		}
+		applies = append(applies, apply)
+	}
+
+	globalstate.StoreSettings(next)
+
+	for _, apply := range applies {
+		apply()
+	}
+
+	appmetrics.ConfigHash.Reset()
+	appmetrics.ConfigHash.WithLabelValues(next.Hash).Set(1)
+
+	log.With("hash", next.Hash).Info("reloaded settings")
+
+	return nil
+}
+
+// A missing file hashes like an empty one, as Load treats it when env vars provide the settings,
+// so an env-only config stays unchanged and creating or deleting the file triggers a reload
+func fileHash() (string, error) {
+	contents, err := os.ReadFile(globalstate.Settings().Path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	return appsettings.ContentHash(contents), nil
+}
+
+// Reload the settings on SIGHUP and, if [reload] watch_file is set, whenever the file changes, until ctx is cancelled
+func Run(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	// Don't retry a broken file on every poll, only once it changes again
+	var failedHash string
+
+	for {
+		// Re-armed on every iteration, so a reload can turn the watcher on or off and change its interval
+		var poll <-chan time.Time
+		if settings := globalstate.Settings(); settings.Reload.WatchFile {
+			poll = time.After(settings.Reload.WatchInterval.Duration)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			log.Info("received SIGHUP, reloading settings")
+			if err := Reload(); err != nil {
+				log.WithError(err).Error("failed reloading settings, keeping the current ones")
+			}
+		case <-poll:
+			hash, err := fileHash()
+			if err != nil {
+				log.WithError(err).Warn("failed checking the settings file for changes")
+				continue
+			}
+			if hash == globalstate.Settings().Hash || hash == failedHash {
+				continue
+			}
+
+			log.Info("settings file changed, reloading settings")
+			if err := Reload(); err != nil {
+				failedHash = hash
+				log.WithError(err).Error("failed reloading settings, keeping the current ones")
+			}
+		}
+	}
+}
@@ -0,0 +1,199 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	appsettings "azure_app_exporter/appSettings"
+	buildinfo "azure_app_exporter/buildInfo"
+	"azure_app_exporter/logging"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Set on the client span of every Graph response, Microsoft support asks for it when investigating a request
+// https://learn.microsoft.com/en-us/graph/best-practices-concept#reliability-and-support
+const GraphRequestIdKey = attribute.Key("graph.request_id")
+
+// Set on the spans about a single tenant
+const TenantKey = attribute.Key("tenant")
+
+// The tracer of the exporter, spans are dropped until Init installs an exporter
+func Tracer() trace.Tracer {
+	return otel.Tracer("azure_app_exporter")
+}
+
+// Propagate W3C trace context and, when enabled, export spans to the collector.
+// The returned func flushes the pending spans on shutdown.
+func Init(ctx context.Context, settings appsettings.Tracing) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if !settings.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	log := logging.With("component", "tracing")
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.WithError(err).Warn("opentelemetry error")
+	}))
+
+	var client otlptrace.Client
+	switch settings.Protocol {
+	case appsettings.TracingProtocolGrpc:
+		var options []otlptracegrpc.Option
+		if settings.Endpoint != "" {
+			options = append(options, otlptracegrpc.WithEndpoint(settings.Endpoint))
+		}
+		if settings.Insecure {
+			options = append(options, otlptracegrpc.WithInsecure())
+		}
+		client = otlptracegrpc.NewClient(options...)
+	default:
+		var options []otlptracehttp.Option
+		if settings.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(settings.Endpoint))
+		}
+		if settings.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		client = otlptracehttp.NewClient(options...)
+	}
+
+	exporter, err := otlptrace.New(ctx, client)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating the otlp trace exporter -> %w", err)
+	}
+
+	serviceResource, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(
+			semconv.ServiceName(settings.ServiceName),
+			semconv.ServiceVersion(buildinfo.Version),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating the otlp resource -> %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(serviceResource),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(settings.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	log.With("protocol", settings.Protocol, "endpoint", settings.Endpoint).Info("exporting traces")
+
+	return provider.Shutdown, nil
+}
+
+// Mark span as failed if err is set
+func RecordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// Echo middleware starting a server span per request, continuing the trace of its traceparent header.
+// Requests to skipPaths, like the metrics and health endpoints, are not traced.
+func Middleware(skipPaths ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			route := c.Path()
+			if slices.Contains(skipPaths, route) {
+				return next(c)
+			}
+
+			request := c.Request()
+			ctx := otel.GetTextMapPropagator().Extract(request.Context(), propagation.HeaderCarrier(request.Header))
+
+			name := request.Method
+			if route != "" {
+				name += " " + route
+			}
+
+			ctx, span := Tracer().Start(ctx, name,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPRequestMethodKey.String(request.Method),
+					semconv.HTTPRoute(route),
+					semconv.URLPath(request.URL.Path),
+					semconv.ServerAddress(request.Host),
+					semconv.UserAgentOriginal(request.UserAgent()),
+				),
+			)
+			defer span.End()
+
+			c.SetRequest(request.WithContext(ctx))
+
+			// Let the error handler write the response unless an inner middleware already did, so the span gets
+			// the final status code. The error is handled now, so it's not passed on to be handled again.
+			if err := next(c); err != nil && !c.Response().Committed {
+				c.Error(err)
+			}
+
+			status := c.Response().Status
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+
+			return nil
+		}
+	}
+}
+
+// Wrap rt with a client span per request, which also injects the W3C trace context into the request headers
+func Transport(rt http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(requestIdTransport{rt})
+}
+
+// Copies Graph's request-id response header to the client span started by otelhttp
+type requestIdTransport struct {
+	next http.RoundTripper
+}
+
+func (t requestIdTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(req)
+	if err == nil {
+		if requestId := res.Header.Get("request-id"); requestId != "" {
+			trace.SpanFromContext(req.Context()).SetAttributes(GraphRequestIdKey.String(requestId))
+		}
+	}
+
+	return res, err
+}